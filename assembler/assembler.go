@@ -0,0 +1,391 @@
+// Package assembler translates MIPS-like assembly source into the
+// word-per-line hex image read by Machine.LoadFromReader.
+//
+// Addresses are word addresses. Branch offsets are unshifted and relative to
+// the updated pc (the branch address plus one), and jump targets are
+// absolute, as the README specifies.
+package assembler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Error is an assembly error tied to a source position
+type Error struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// Program is an assembled memory image
+type Program struct {
+	// Words holds one memory word per address, starting at address zero
+	Words []uint32
+	// Labels maps each label to its address
+	Labels map[string]uint32
+}
+
+// WriteImage writes the program as one hex word per line
+func (p *Program) WriteImage(w io.Writer) error {
+	for _, word := range p.Words {
+		if _, err := fmt.Fprintf(w, "%08x\n", word); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statement is a single instruction or data word
+type statement struct {
+	line     int
+	addr     uint32
+	mnemonic string
+	operands []string
+}
+
+type assembler struct {
+	file       string
+	labels     map[string]uint32
+	statements []statement
+}
+
+// Assemble runs both passes over src. name is only used in error positions.
+func Assemble(name string, src io.Reader) (*Program, error) {
+	a := &assembler{file: name, labels: make(map[string]uint32)}
+	if err := a.firstPass(src); err != nil {
+		return nil, err
+	}
+
+	words := make([]uint32, 0, len(a.statements))
+	for _, st := range a.statements {
+		word, err := a.encode(st)
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, word)
+	}
+
+	return &Program{Words: words, Labels: a.labels}, nil
+}
+
+func (a *assembler) errorf(line int, format string, args ...interface{}) error {
+	return &Error{File: a.file, Line: line, Msg: fmt.Sprintf(format, args...)}
+}
+
+// firstPass assigns an address to every statement and records labels
+func (a *assembler) firstPass(src io.Reader) error {
+	scanner := bufio.NewScanner(src)
+	var addr uint32
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if i := strings.Index(text, "//"); i >= 0 {
+			text = text[:i]
+		}
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+		text = strings.TrimSpace(text)
+
+		for {
+			i := strings.Index(text, ":")
+			if i < 0 {
+				break
+			}
+			label := strings.TrimSpace(text[:i])
+			if !isIdent(label) {
+				return a.errorf(line, "invalid label %q", label)
+			}
+			if _, ok := a.labels[label]; ok {
+				return a.errorf(line, "label %q redefined", label)
+			}
+			a.labels[label] = addr
+			text = strings.TrimSpace(text[i+1:])
+		}
+		if text == "" {
+			continue
+		}
+
+		mnemonic, rest := text, ""
+		if i := strings.IndexAny(text, " \t"); i >= 0 {
+			mnemonic, rest = text[:i], strings.TrimSpace(text[i:])
+		}
+		mnemonic = strings.ToLower(mnemonic)
+
+		// a bare value is a data word, as in "a: 0x22"
+		if _, err := parseNumber(mnemonic); err == nil {
+			if rest != "" {
+				return a.errorf(line, "unexpected %q after data word", rest)
+			}
+			rest, mnemonic = mnemonic, ".word"
+		}
+
+		operands := splitOperands(rest)
+		if mnemonic == ".word" {
+			if len(operands) == 0 {
+				return a.errorf(line, ".word needs at least one value")
+			}
+			for _, op := range operands {
+				a.statements = append(a.statements, statement{line, addr, ".word", []string{op}})
+				addr++
+			}
+			continue
+		}
+
+		if _, ok := instructions[mnemonic]; !ok {
+			return a.errorf(line, "unknown instruction %q", mnemonic)
+		}
+		a.statements = append(a.statements, statement{line, addr, mnemonic, operands})
+		addr++
+	}
+	return scanner.Err()
+}
+
+func (a *assembler) encode(st statement) (uint32, error) {
+	if st.mnemonic == ".word" {
+		v, err := a.value(st.line, st.operands[0])
+		if err != nil {
+			return 0, err
+		}
+		if v < -(1<<31) || v >= 1<<32 {
+			return 0, a.errorf(st.line, "value %s does not fit in a word", st.operands[0])
+		}
+		return uint32(v), nil
+	}
+
+	inst := instructions[st.mnemonic]
+	ops := st.operands
+	if n, ok := operands[inst.syntax]; ok && len(ops) != n {
+		return 0, a.errorf(st.line, "%s expects %d operands, got %d", st.mnemonic, n, len(ops))
+	}
+
+	// regs parses the named operands as registers in order
+	regs := func(idx ...int) ([]uint32, error) {
+		out := make([]uint32, len(idx))
+		for i, n := range idx {
+			r, err := a.register(st.line, ops[n])
+			if err != nil {
+				return nil, err
+			}
+			out[i] = r
+		}
+		return out, nil
+	}
+
+	switch inst.syntax {
+	case synNone:
+		return 0, nil
+	case synRdRsRt:
+		r, err := regs(0, 1, 2)
+		if err != nil {
+			return 0, err
+		}
+		return encodeR(inst.op, r[1], r[2], r[0], 0, inst.funct), nil
+	case synRdRtShamt:
+		r, err := regs(0, 1)
+		if err != nil {
+			return 0, err
+		}
+		shamt, err := a.value(st.line, ops[2])
+		if err != nil {
+			return 0, err
+		}
+		if shamt < 0 || shamt > 31 {
+			return 0, a.errorf(st.line, "shift amount %d out of range", shamt)
+		}
+		return encodeR(inst.op, 0, r[1], r[0], uint32(shamt), inst.funct), nil
+	case synRs:
+		r, err := regs(0)
+		if err != nil {
+			return 0, err
+		}
+		return encodeR(inst.op, r[0], 0, 0, 0, inst.funct), nil
+	case synJalr:
+		switch len(ops) {
+		case 1:
+			r, err := regs(0)
+			if err != nil {
+				return 0, err
+			}
+			return encodeR(inst.op, r[0], 0, 31, 0, inst.funct), nil
+		case 2:
+			r, err := regs(0, 1)
+			if err != nil {
+				return 0, err
+			}
+			return encodeR(inst.op, r[1], 0, r[0], 0, inst.funct), nil
+		}
+		return 0, a.errorf(st.line, "jalr expects 1 or 2 operands, got %d", len(ops))
+	case synRtRsImm:
+		r, err := regs(0, 1)
+		if err != nil {
+			return 0, err
+		}
+		imm, err := a.immediate(st.line, ops[2])
+		if err != nil {
+			return 0, err
+		}
+		return encodeI(inst.op, r[1], r[0], imm), nil
+	case synRtImm:
+		r, err := regs(0)
+		if err != nil {
+			return 0, err
+		}
+		imm, err := a.immediate(st.line, ops[1])
+		if err != nil {
+			return 0, err
+		}
+		return encodeI(inst.op, 0, r[0], imm), nil
+	case synRtMem:
+		r, err := regs(0)
+		if err != nil {
+			return 0, err
+		}
+		base, imm, err := a.memory(st.line, ops[1])
+		if err != nil {
+			return 0, err
+		}
+		return encodeI(inst.op, base, r[0], imm), nil
+	case synRsRtLabel:
+		r, err := regs(0, 1)
+		if err != nil {
+			return 0, err
+		}
+		off, err := a.branchOffset(st, ops[2])
+		if err != nil {
+			return 0, err
+		}
+		return encodeI(inst.op, r[0], r[1], off), nil
+	case synRsLabel:
+		r, err := regs(0)
+		if err != nil {
+			return 0, err
+		}
+		off, err := a.branchOffset(st, ops[1])
+		if err != nil {
+			return 0, err
+		}
+		return encodeI(inst.op, r[0], 0, off), nil
+	case synTarget:
+		target, err := a.value(st.line, ops[0])
+		if err != nil {
+			return 0, err
+		}
+		if target < 0 || target > 0x03ffffff {
+			return 0, a.errorf(st.line, "jump target %#x out of range", target)
+		}
+		return encodeJ(inst.op, uint32(target)), nil
+	}
+
+	return 0, a.errorf(st.line, "cannot encode %s", st.mnemonic)
+}
+
+// register parses r0-r31 (or $0-$31)
+func (a *assembler) register(line int, s string) (uint32, error) {
+	if len(s) > 1 && (s[0] == 'r' || s[0] == 'R' || s[0] == '$') {
+		n, err := strconv.Atoi(s[1:])
+		if err == nil && n >= 0 && n < 32 {
+			return uint32(n), nil
+		}
+	}
+	return 0, a.errorf(line, "invalid register %q", s)
+}
+
+// value resolves a numeric literal or a label address
+func (a *assembler) value(line int, s string) (int64, error) {
+	if v, err := parseNumber(s); err == nil {
+		return v, nil
+	}
+	if addr, ok := a.labels[s]; ok {
+		return int64(addr), nil
+	}
+	if isIdent(s) {
+		return 0, a.errorf(line, "undefined label %q", s)
+	}
+	return 0, a.errorf(line, "invalid value %q", s)
+}
+
+// immediate resolves a 16-bit immediate, accepting signed or unsigned values
+func (a *assembler) immediate(line int, s string) (uint16, error) {
+	v, err := a.value(line, s)
+	if err != nil {
+		return 0, err
+	}
+	if v < -(1<<15) || v >= 1<<16 {
+		return 0, a.errorf(line, "immediate %s does not fit in 16 bits", s)
+	}
+	return uint16(v), nil
+}
+
+// memory parses "immed(rs)", "label(rs)", "label" or "immed"
+func (a *assembler) memory(line int, s string) (uint32, uint16, error) {
+	if i := strings.Index(s, "("); i >= 0 {
+		if !strings.HasSuffix(s, ")") {
+			return 0, 0, a.errorf(line, "invalid memory operand %q", s)
+		}
+		base, err := a.register(line, strings.TrimSpace(s[i+1:len(s)-1]))
+		if err != nil {
+			return 0, 0, err
+		}
+		off := strings.TrimSpace(s[:i])
+		if off == "" {
+			return base, 0, nil
+		}
+		imm, err := a.immediate(line, off)
+		return base, imm, err
+	}
+	imm, err := a.immediate(line, s)
+	return 0, imm, err
+}
+
+// branchOffset computes the unshifted offset from the updated pc to target
+func (a *assembler) branchOffset(st statement, s string) (uint16, error) {
+	target, err := a.value(st.line, s)
+	if err != nil {
+		return 0, err
+	}
+	off := target - int64(st.addr+1)
+	if off < -(1<<15) || off >= 1<<15 {
+		return 0, a.errorf(st.line, "branch to %s out of range", s)
+	}
+	return uint16(int16(off)), nil
+}
+
+func splitOperands(s string) []string {
+	if s == "" {
+		return nil
+	}
+	parts := strings.Split(s, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
+
+func parseNumber(s string) (int64, error) {
+	return strconv.ParseInt(s, 0, 64)
+}
+
+func isIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		switch {
+		case c == '_' || c == '.' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
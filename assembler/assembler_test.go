@@ -0,0 +1,117 @@
+package assembler
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readHex(t *testing.T, path string) []uint32 {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var words []uint32
+	for _, line := range strings.Fields(string(b)) {
+		var w uint32
+		if _, err := fmt.Sscanf(line, "%x", &w); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		words = append(words, w)
+	}
+	return words
+}
+
+func TestREADMEPrograms(t *testing.T) {
+	for _, name := range []string{"readme_sub", "readme_loop"} {
+		t.Run(name, func(t *testing.T) {
+			src, err := os.Open(filepath.Join("testdata", name+".s"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer src.Close()
+
+			prog, err := Assemble(name+".s", src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := readHex(t, filepath.Join("testdata", name+".hex"))
+			if fmt.Sprint(prog.Words) != fmt.Sprint(want) {
+				t.Errorf("got  %08x\nwant %08x", prog.Words, want)
+			}
+
+			var buf bytes.Buffer
+			if err := prog.WriteImage(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if lines := strings.Count(buf.String(), "\n"); lines != len(want) {
+				t.Errorf("image has %d lines, want %d", lines, len(want))
+			}
+		})
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	tests := []struct {
+		src  string
+		want uint32
+	}{
+		{"addu r1, r2, r3", 0x00430821},
+		{"mul r3, r1, r2", 0x70221802},
+		{"sll r1, r2, 4", 0x00020900},
+		{"sra r1, r2, 31", 0x00020fc3},
+		{"jr r31", 0x03e00008},
+		{"jalr r5", 0x00a0f809},
+		{"jalr r4, r5", 0x00a02009},
+		{"addiu r1, r0, -1", 0x2401ffff},
+		{"xori r1, r2, 0xffff", 0x3841ffff},
+		{"lui r1, 0x1234", 0x3c011234},
+		{"lw r1, 4(r2)", 0x8c410004},
+		{"sw r1, -4(r2)", 0xac41fffc},
+		{"lw r1, (r2)", 0x8c410000},
+		{"x: beq r1, r2, x", 0x1022ffff},
+		{"bgtz r1, y\ny: hlt", 0x1c200000},
+		{"jal 0x10", 0x0c000010},
+		{"hlt", 0},
+		{".word -1", 0xffffffff},
+		{"0x22", 0x22},
+	}
+	for _, tt := range tests {
+		prog, err := Assemble("t.s", strings.NewReader(tt.src))
+		if err != nil {
+			t.Errorf("%q: %v", tt.src, err)
+			continue
+		}
+		if prog.Words[0] != tt.want {
+			t.Errorf("%q: got %08x, want %08x", tt.src, prog.Words[0], tt.want)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"hlt\nfoo r1", `t.s:2: unknown instruction "foo"`},
+		{"j nowhere", `t.s:1: undefined label "nowhere"`},
+		{"addu r1, r2, r32", `t.s:1: invalid register "r32"`},
+		{"a: hlt\na: hlt", `t.s:2: label "a" redefined`},
+		{"\n\naddiu r1, r0, 0x10000", "t.s:3: immediate 0x10000 does not fit in 16 bits"},
+		{"addu r1, r2", "t.s:1: addu expects 3 operands, got 2"},
+		{"sll r1, r2, 32", "t.s:1: shift amount 32 out of range"},
+	}
+	for _, tt := range tests {
+		_, err := Assemble("t.s", strings.NewReader(tt.src))
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%q: got %v, want %s", tt.src, err, tt.want)
+		}
+		if _, ok := err.(*Error); err != nil && !ok {
+			t.Errorf("%q: %T is not an *Error", tt.src, err)
+		}
+	}
+}
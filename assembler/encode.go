@@ -0,0 +1,82 @@
+package assembler
+
+// syntax describes the operand list an instruction accepts
+type syntax int
+
+const (
+	// hlt
+	synNone syntax = iota
+	// addu rd, rs, rt
+	synRdRsRt
+	// sll rd, rt, shamt
+	synRdRtShamt
+	// jr rs
+	synRs
+	// jalr rd, rs (or jalr rs, which links r31)
+	synJalr
+	// addiu rt, rs, immed
+	synRtRsImm
+	// lui rt, immed
+	synRtImm
+	// lw rt, immed(rs) or lw rt, label
+	synRtMem
+	// beq rs, rt, label
+	synRsRtLabel
+	// blez rs, label
+	synRsLabel
+	// j label
+	synTarget
+)
+
+type instruction struct {
+	op     uint32
+	funct  uint32
+	syntax syntax
+}
+
+// operands is the number of operands each syntax takes. jalr takes one or
+// two and is checked when it is encoded.
+var operands = map[syntax]int{
+	synNone: 0, synRdRsRt: 3, synRdRtShamt: 3, synRs: 1, synRtRsImm: 3,
+	synRtImm: 2, synRtMem: 2, synRsRtLabel: 3, synRsLabel: 2, synTarget: 1,
+}
+
+var instructions = map[string]instruction{
+	"addu":  {0x00, 0x21, synRdRsRt},
+	"addiu": {0x09, 0, synRtRsImm},
+	"and":   {0x00, 0x24, synRdRsRt},
+	"beq":   {0x04, 0, synRsRtLabel},
+	"bgtz":  {0x07, 0, synRsLabel},
+	"blez":  {0x06, 0, synRsLabel},
+	"bne":   {0x05, 0, synRsRtLabel},
+	"hlt":   {0x00, 0x00, synNone},
+	"j":     {0x02, 0, synTarget},
+	"jal":   {0x03, 0, synTarget},
+	"jalr":  {0x00, 0x09, synJalr},
+	"jr":    {0x00, 0x08, synRs},
+	"lui":   {0x0f, 0, synRtImm},
+	"lw":    {0x23, 0, synRtMem},
+	"mul":   {0x1c, 0x02, synRdRsRt},
+	"nor":   {0x00, 0x27, synRdRsRt},
+	"or":    {0x00, 0x25, synRdRsRt},
+	"sll":   {0x00, 0x00, synRdRtShamt},
+	"slti":  {0x0a, 0, synRtRsImm},
+	"sra":   {0x00, 0x03, synRdRtShamt},
+	"srl":   {0x00, 0x02, synRdRtShamt},
+	"subu":  {0x00, 0x23, synRdRsRt},
+	"sw":    {0x2b, 0, synRtMem},
+	"xor":   {0x00, 0x26, synRdRsRt},
+	"xori":  {0x0e, 0, synRtRsImm},
+}
+
+func encodeR(op, rs, rt, rd, shamt, funct uint32) uint32 {
+	return op<<26 | rs<<21 | rt<<16 | rd<<11 | shamt<<6 | funct
+}
+
+func encodeI(op, rs, rt uint32, imm uint16) uint32 {
+	return op<<26 | rs<<21 | rt<<16 | uint32(imm)
+}
+
+func encodeJ(op, target uint32) uint32 {
+	return op<<26 | target&0x03ffffff
+}
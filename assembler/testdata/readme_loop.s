@@ -0,0 +1,8 @@
+start: addiu r3, r0, 5  // n = 5
+      addu  r1, r0, r0 // sum = 0
+      addiu r2, r0, 1  // i = 1
+loop:  addu  r1, r1, r2 // sum = sum + i
+      addiu r2, r2, 1  // i = i + 1
+      subu  r4, r2, r3 // temp = i - n
+      blez  r4, loop   // branch if temp <= 0
+      hlt
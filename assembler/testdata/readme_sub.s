@@ -0,0 +1,9 @@
+start: j    main
+a:     0x22
+b:     0x23
+c:     0x0
+main:  lw   r1,a
+       lw   r2,b
+       subu r3, r1, r2
+       sw   r3, c
+       hlt
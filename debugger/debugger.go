@@ -0,0 +1,372 @@
+// Package debugger implements an interactive command-line debugger on top
+// of machine.Machine.
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/t94j0/cpsc_3300_mips/disasm"
+	machine "github.com/t94j0/cpsc_3300_mips/machine"
+	binary "github.com/t94j0/go-mips-instruction-format"
+)
+
+const help = `commands (addresses and values are hexadecimal, 0x prefix optional;
+a name that is both a label and a hex number means the label, so write
+0xa for address a when a label "a" exists):
+  break <addr|label>     stop before executing addr
+  delete <addr|label>    remove a breakpoint
+  watch mem[<addr>]      stop when a memory word changes
+  watch r<n>             stop when a register changes
+  step [count]           execute count (decimal) instructions, default 1
+  next                   step, running jal/jalr calls to their return
+  continue               run until a breakpoint, watchpoint or halt
+  regs                   show pc and registers
+  mem <from> <to>        show memory words from..to inclusive
+  set r<n> <value>       change a register to a hexadecimal value
+  help                   show this text
+  quit                   leave the debugger`
+
+// watch is a register (reg >= 0) or memory word being watched
+type watch struct {
+	reg   int
+	addr  uint32
+	value uint32
+}
+
+func (w *watch) String() string {
+	if w.reg >= 0 {
+		return fmt.Sprintf("r%d", w.reg)
+	}
+	return fmt.Sprintf("mem[%03x]", w.addr)
+}
+
+type Debugger struct {
+	m      *machine.Machine
+	out    io.Writer
+	labels map[string]uint32
+	names  map[uint32]string
+
+	breaks  map[uint32]bool
+	watches []*watch
+}
+
+// New creates a debugger for m. labels names addresses for break and for
+// display; when nil, labels are synthesized from the memory image.
+func New(m *machine.Machine, labels map[string]uint32, out io.Writer) *Debugger {
+	d := &Debugger{
+		m:      m,
+		out:    out,
+		labels: make(map[string]uint32),
+		names:  make(map[uint32]string),
+		breaks: make(map[uint32]bool),
+	}
+	if labels == nil {
+		for addr, name := range disasm.Labels(m.Memory()) {
+			d.labels[name] = addr
+		}
+	} else {
+		for name, addr := range labels {
+			d.labels[name] = addr
+		}
+	}
+	for name, addr := range d.labels {
+		if old, ok := d.names[addr]; !ok || name < old {
+			d.names[addr] = name
+		}
+	}
+	return d
+}
+
+// Run reads commands from in until quit or end of input
+func (d *Debugger) Run(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	d.where()
+	for {
+		fmt.Fprint(d.out, "(mips) ")
+		if !scanner.Scan() {
+			fmt.Fprintln(d.out)
+			return scanner.Err()
+		}
+		quit, err := d.Exec(scanner.Text())
+		if err != nil {
+			fmt.Fprintln(d.out, "error:", err)
+		}
+		if quit {
+			return nil
+		}
+	}
+}
+
+// Exec runs a single command line
+func (d *Debugger) Exec(line string) (quit bool, err error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false, nil
+	}
+	cmd, args := fields[0], fields[1:]
+
+	switch cmd {
+	case "break", "b":
+		if len(args) != 1 {
+			return false, fmt.Errorf("usage: break <addr|label>")
+		}
+		addr, err := d.address(args[0])
+		if err != nil {
+			return false, err
+		}
+		d.breaks[addr] = true
+		fmt.Fprintf(d.out, "breakpoint at %s\n", d.name(addr))
+	case "delete", "d":
+		if len(args) != 1 {
+			return false, fmt.Errorf("usage: delete <addr|label>")
+		}
+		addr, err := d.address(args[0])
+		if err != nil {
+			return false, err
+		}
+		if !d.breaks[addr] {
+			return false, fmt.Errorf("no breakpoint at %s", d.name(addr))
+		}
+		delete(d.breaks, addr)
+	case "watch", "w":
+		if len(args) != 1 {
+			return false, fmt.Errorf("usage: watch mem[<addr>] | watch r<n>")
+		}
+		return false, d.watch(args[0])
+	case "step", "s":
+		n := uint64(1)
+		if len(args) == 1 {
+			if n, err = strconv.ParseUint(args[0], 10, 32); err != nil || n < 1 {
+				return false, fmt.Errorf("invalid count %q", args[0])
+			}
+		}
+		return false, d.run(func(int, machine.StepResult) bool { n--; return n == 0 })
+	case "next", "n":
+		return false, d.next()
+	case "continue", "c":
+		return false, d.run(func(int, machine.StepResult) bool { return false })
+	case "regs", "r":
+		d.regs()
+	case "mem", "m":
+		if len(args) != 2 {
+			return false, fmt.Errorf("usage: mem <from> <to>")
+		}
+		return false, d.mem(args[0], args[1])
+	case "set":
+		if len(args) != 2 {
+			return false, fmt.Errorf("usage: set r<n> <value>")
+		}
+		n, err := register(args[0])
+		if err != nil {
+			return false, err
+		}
+		v, err := number(args[1])
+		if err != nil {
+			return false, err
+		}
+		return false, d.m.SetRegister(n, v)
+	case "help", "h", "?":
+		fmt.Fprintln(d.out, help)
+	case "quit", "q":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown command %q (try help)", cmd)
+	}
+	return false, nil
+}
+
+// run steps the machine until done reports true, a breakpoint is reached,
+// a watchpoint changes, or the machine halts. depth counts the calls
+// entered so far.
+func (d *Debugger) run(done func(depth int, r machine.StepResult) bool) error {
+	depth := 0
+	for {
+		if d.m.Halted() {
+			fmt.Fprintln(d.out, "program halted")
+			return nil
+		}
+		r, err := d.m.Step()
+		if err != nil {
+			return err
+		}
+		switch {
+		case r.Mnemonic == "jal" || r.Mnemonic == "jalr":
+			depth++
+		case r.Mnemonic == "jr" && binary.GetOperation(r.Inst) == 0 && rs(r.Inst) == 31:
+			depth--
+		}
+
+		stop := done(depth, r)
+		for _, w := range d.watches {
+			if v := d.read(w); v != w.value {
+				fmt.Fprintf(d.out, "watchpoint %s: %08x -> %08x\n", w, w.value, v)
+				w.value = v
+				stop = true
+			}
+		}
+		if d.breaks[d.m.PC()] && !d.m.Halted() {
+			fmt.Fprintf(d.out, "breakpoint at %s\n", d.name(d.m.PC()))
+			stop = true
+		}
+		if stop || d.m.Halted() {
+			d.where()
+			return nil
+		}
+	}
+}
+
+// next steps over jal and jalr, stopping once the call has returned
+func (d *Debugger) next() error {
+	word, err := d.m.Word(d.m.PC())
+	if err != nil {
+		return err
+	}
+	inst, _ := disasm.Decode(d.m.PC(), word)
+	if inst.Mnemonic != "jal" && inst.Mnemonic != "jalr" {
+		return d.run(func(int, machine.StepResult) bool { return true })
+	}
+	ret := d.m.PC() + 1
+	return d.run(func(depth int, r machine.StepResult) bool {
+		return depth <= 0 && r.Target == ret
+	})
+}
+
+// where prints the next instruction to execute
+func (d *Debugger) where() {
+	if d.m.Halted() {
+		fmt.Fprintln(d.out, "program halted")
+		return
+	}
+	pc := d.m.PC()
+	word, err := d.m.Word(pc)
+	if err != nil {
+		fmt.Fprintf(d.out, "%s: %v\n", d.name(pc), err)
+		return
+	}
+	text := fmt.Sprintf(".word 0x%08x", word)
+	if inst, ok := disasm.Decode(pc, word); ok {
+		ops := inst.Operands
+		for _, t := range inst.Targets {
+			ops = append(ops, d.name(t))
+		}
+		text = fmt.Sprintf("%-6s%s", inst.Mnemonic, strings.Join(ops, ", "))
+	}
+	fmt.Fprintf(d.out, "%s: %08x  %s\n", d.name(pc), word, strings.TrimSpace(text))
+}
+
+func (d *Debugger) regs() {
+	regs := d.m.Registers()
+	fmt.Fprintf(d.out, "pc  = %03x\n", d.m.PC())
+	for i := 0; i < 32; i += 4 {
+		fmt.Fprintf(d.out, "r%-2d = %08x  r%-2d = %08x  r%-2d = %08x  r%-2d = %08x\n",
+			i, regs[i], i+1, regs[i+1], i+2, regs[i+2], i+3, regs[i+3])
+	}
+}
+
+func (d *Debugger) mem(fromArg, toArg string) error {
+	from, err := d.address(fromArg)
+	if err != nil {
+		return err
+	}
+	to, err := d.address(toArg)
+	if err != nil {
+		return err
+	}
+	for addr := from; addr <= to; addr++ {
+		word, err := d.m.Word(addr)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(d.out, "%03x: %08x", addr, word)
+		if name, ok := d.names[addr]; ok {
+			fmt.Fprintf(d.out, "  <%s>", name)
+		}
+		fmt.Fprintln(d.out)
+	}
+	return nil
+}
+
+func (d *Debugger) watch(arg string) error {
+	w := &watch{reg: -1}
+	if strings.HasPrefix(arg, "mem[") && strings.HasSuffix(arg, "]") {
+		addr, err := d.address(arg[4 : len(arg)-1])
+		if err != nil {
+			return err
+		}
+		if _, err := d.m.Word(addr); err != nil {
+			return err
+		}
+		w.addr = addr
+	} else {
+		n, err := register(arg)
+		if err != nil {
+			return err
+		}
+		w.reg = n
+	}
+	w.value = d.read(w)
+	d.watches = append(d.watches, w)
+	fmt.Fprintf(d.out, "watching %s = %08x\n", w, w.value)
+	return nil
+}
+
+func (d *Debugger) read(w *watch) uint32 {
+	if w.reg >= 0 {
+		v, _ := d.m.Register(w.reg)
+		return v
+	}
+	v, _ := d.m.Word(w.addr)
+	return v
+}
+
+// name formats addr with its label, if any
+func (d *Debugger) name(addr uint32) string {
+	if name, ok := d.names[addr]; ok {
+		return fmt.Sprintf("%03x <%s>", addr, name)
+	}
+	return fmt.Sprintf("%03x", addr)
+}
+
+// address resolves a label or hexadecimal address. A 0x prefix always
+// means a number, so labels such as "a" cannot hide address 0xa.
+func (d *Debugger) address(s string) (uint32, error) {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		return number(s)
+	}
+	if addr, ok := d.labels[s]; ok {
+		return addr, nil
+	}
+	return number(s)
+}
+
+func rs(inst uint32) uint16 {
+	s, _, _, _, _ := binary.GetRFormat(inst)
+	return s
+}
+
+func register(s string) (int, error) {
+	if len(s) > 1 && (s[0] == 'r' || s[0] == '$') {
+		if n, err := strconv.Atoi(s[1:]); err == nil && n >= 0 && n < 32 {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid register %q", s)
+}
+
+// number parses a hexadecimal value with an optional 0x prefix and sign
+func number(s string) (uint32, error) {
+	neg := strings.HasPrefix(s, "-")
+	digits := strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(s, "-"), "0x"), "0X")
+	v, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	if neg {
+		return uint32(-int64(v)), nil
+	}
+	return uint32(v), nil
+}
@@ -0,0 +1,159 @@
+package debugger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/t94j0/cpsc_3300_mips/assembler"
+	machine "github.com/t94j0/cpsc_3300_mips/machine"
+)
+
+const program = `
+main:	addiu r1, r0, 2
+	jal   double
+	sw    r2, result(r0)
+	hlt
+double:	addu  r2, r1, r1
+	jr    r31
+result:	.word 0
+`
+
+func newDebugger(t *testing.T) (*Debugger, *machine.Machine, *bytes.Buffer) {
+	t.Helper()
+	prog, err := assembler.Assemble("test.s", strings.NewReader(program))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := machine.NewMachine()
+	m.LoadWords(prog.Words)
+	var out bytes.Buffer
+	return New(m, prog.Labels, &out), m, &out
+}
+
+func TestExec(t *testing.T) {
+	type command struct {
+		line string
+		pc   uint32
+		out  string
+		err  bool
+	}
+	tests := []struct {
+		name     string
+		commands []command
+	}{
+		{"step", []command{
+			{line: "step", pc: 1, out: "001: 0c000004  jal   004 <double>"},
+			{line: "step 2", pc: 5},
+			{line: "s", pc: 2, out: "002: ac020006  sw"},
+		}},
+		{"step rejects bad counts", []command{
+			{line: "step 0", err: true},
+			{line: "step -1", err: true},
+			{line: "step 0x2", err: true},
+			{line: "step x", err: true},
+		}},
+		{"break and continue", []command{
+			{line: "break double", out: "breakpoint at 004 <double>"},
+			{line: "continue", pc: 4, out: "breakpoint at 004 <double>"},
+			{line: "delete double", pc: 4},
+			{line: "delete double", pc: 4, err: true},
+			{line: "c", pc: 4, out: "program halted"},
+		}},
+		{"next steps over calls", []command{
+			{line: "next", pc: 1},
+			{line: "next", pc: 2, out: "002: ac020006  sw"},
+			{line: "next", pc: 3},
+		}},
+		{"next stops at breakpoints inside calls", []command{
+			{line: "step", pc: 1},
+			{line: "b 5", pc: 1},
+			{line: "next", pc: 5, out: "breakpoint at 005"},
+		}},
+		{"watch memory", []command{
+			{line: "watch mem[result]", out: "watching mem[006] = 00000000"},
+			{line: "c", pc: 3, out: "watchpoint mem[006]: 00000000 -> 00000004"},
+		}},
+		{"watch register", []command{
+			{line: "watch r2", out: "watching r2 = 00000000"},
+			{line: "c", pc: 5, out: "watchpoint r2: 00000000 -> 00000004"},
+			{line: "watch r32", pc: 5, err: true},
+		}},
+		{"set and regs", []command{
+			{line: "set r5 -1"},
+			{line: "set r0 7"},
+			{line: "regs", out: "r4  = 00000000  r5  = ffffffff"},
+			{line: "regs", out: "r0  = 00000000"},
+			{line: "set r32 1", err: true},
+		}},
+		{"mem", []command{
+			{line: "mem 5 result", out: "005: 03e00008\n006: 00000000  <result>\n"},
+			{line: "mem 0 400", err: true},
+		}},
+		{"unknown", []command{
+			{line: "frobnicate", err: true},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, m, out := newDebugger(t)
+			for _, c := range tt.commands {
+				out.Reset()
+				quit, err := d.Exec(c.line)
+				if quit {
+					t.Fatalf("%s: quit", c.line)
+				}
+				if (err != nil) != c.err {
+					t.Fatalf("%s: err %v", c.line, err)
+				}
+				if m.PC() != c.pc {
+					t.Errorf("%s: pc %03x, want %03x", c.line, m.PC(), c.pc)
+				}
+				if !strings.Contains(out.String(), c.out) {
+					t.Errorf("%s: output %q does not contain %q", c.line, out.String(), c.out)
+				}
+			}
+		})
+	}
+}
+
+func TestRunQuits(t *testing.T) {
+	d, _, out := newDebugger(t)
+	if err := d.Run(strings.NewReader("s\nbogus\nquit\ns\n")); err != nil {
+		t.Fatal(err)
+	}
+	want := "000 <main>: 24010002  addiu r1, r0, 2\n" +
+		"(mips) 001: 0c000004  jal   004 <double>\n" +
+		"(mips) error: unknown command \"bogus\" (try help)\n" +
+		"(mips) "
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestLabelsDoNotHideHex(t *testing.T) {
+	prog, err := assembler.Assemble("t.s", strings.NewReader("a: hlt\n.word 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := machine.NewMachine()
+	m.LoadWords(prog.Words)
+	var out bytes.Buffer
+	d := New(m, prog.Labels, &out)
+
+	for line, want := range map[string]string{
+		"mem a a":     "000: 00000000  <a>\n",
+		"mem 0xa 0xa": "00a: 0000000a\n",
+		"mem 0XA 0xa": "00a: 0000000a\n",
+		"mem b b":     "00b: 0000000b\n",
+	} {
+		out.Reset()
+		if _, err := d.Exec(line); err != nil {
+			t.Errorf("%s: %v", line, err)
+		}
+		if out.String() != want {
+			t.Errorf("%s: got %q, want %q", line, out.String(), want)
+		}
+	}
+}
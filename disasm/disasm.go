@@ -0,0 +1,214 @@
+// Package disasm turns a memory image back into annotated assembly that
+// the assembler package accepts.
+//
+// Branch targets follow the README: the unshifted offset is added to the
+// updated pc (the branch address plus one). Jump targets are absolute word
+// addresses.
+package disasm
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	binary "github.com/t94j0/go-mips-instruction-format"
+)
+
+// Instruction is a decoded memory word
+type Instruction struct {
+	Mnemonic string
+	Operands []string
+	// Targets are the control transfer destinations of the instruction
+	Targets []uint32
+	// Falls is true when execution can continue at the next address
+	Falls bool
+	// DataRef is set for lw/sw with an absolute (r0-based) address
+	DataRef *uint32
+}
+
+var rMnemonics = map[uint32]string{
+	0x21: "addu", 0x24: "and", 0x09: "jalr", 0x08: "jr", 0x27: "nor",
+	0x25: "or", 0x00: "sll", 0x03: "sra", 0x02: "srl", 0x23: "subu", 0x26: "xor",
+}
+
+func reg(r uint16) string {
+	return fmt.Sprintf("r%d", r)
+}
+
+// Decode decodes the word at addr. ok is false when word is not a valid
+// instruction.
+func Decode(addr, word uint32) (inst Instruction, ok bool) {
+	if word == 0 {
+		return Instruction{Mnemonic: "hlt"}, true
+	}
+
+	op := binary.GetOperation(word)
+	inst.Falls = true
+	switch op {
+	case 0x00:
+		funct := binary.GetFunct(word)
+		name, ok := rMnemonics[funct]
+		if !ok {
+			return inst, false
+		}
+		s, t, d, h, _ := binary.GetRFormat(word)
+		inst.Mnemonic = name
+		switch name {
+		case "sll", "sra", "srl":
+			inst.Operands = []string{reg(d), reg(t), fmt.Sprintf("%d", h)}
+		case "jr":
+			inst.Operands = []string{reg(s)}
+			inst.Falls = false
+		case "jalr":
+			inst.Operands = []string{reg(d), reg(s)}
+		default:
+			inst.Operands = []string{reg(d), reg(s), reg(t)}
+		}
+	case 0x1c:
+		s, t, d, _, funct := binary.GetRFormat(word)
+		if funct != 0x02 {
+			return inst, false
+		}
+		inst.Mnemonic = "mul"
+		inst.Operands = []string{reg(d), reg(s), reg(t)}
+	case 0x02, 0x03:
+		target := binary.GetJFormat(word)
+		inst.Mnemonic = map[uint32]string{0x02: "j", 0x03: "jal"}[op]
+		inst.Targets = []uint32{target}
+		inst.Falls = op == 0x03
+	case 0x04, 0x05, 0x06, 0x07:
+		s, t, imm := binary.GetIFormat(word)
+		target := addr + 1 + uint32(int32(int16(imm)))
+		inst.Mnemonic = map[uint32]string{0x04: "beq", 0x05: "bne", 0x06: "blez", 0x07: "bgtz"}[op]
+		inst.Targets = []uint32{target}
+		if op == 0x04 || op == 0x05 {
+			inst.Operands = []string{reg(s), reg(t)}
+		} else {
+			inst.Operands = []string{reg(s)}
+		}
+	case 0x09, 0x0a, 0x0e:
+		s, t, imm := binary.GetIFormat(word)
+		inst.Mnemonic = map[uint32]string{0x09: "addiu", 0x0a: "slti", 0x0e: "xori"}[op]
+		if op == 0x0e {
+			inst.Operands = []string{reg(t), reg(s), fmt.Sprintf("%#x", imm)}
+		} else {
+			inst.Operands = []string{reg(t), reg(s), fmt.Sprintf("%d", int16(imm))}
+		}
+	case 0x0f:
+		_, t, imm := binary.GetIFormat(word)
+		inst.Mnemonic = "lui"
+		inst.Operands = []string{reg(t), fmt.Sprintf("%#x", imm)}
+	case 0x23, 0x2b:
+		s, t, imm := binary.GetIFormat(word)
+		inst.Mnemonic = map[uint32]string{0x23: "lw", 0x2b: "sw"}[op]
+		inst.Operands = []string{reg(t), fmt.Sprintf("%d(%s)", int16(imm), reg(s))}
+		if s == 0 {
+			ref := uint32(int32(int16(imm)))
+			inst.DataRef = &ref
+		}
+	default:
+		return inst, false
+	}
+	return inst, true
+}
+
+type line struct {
+	inst  Instruction
+	code  bool
+	valid bool
+}
+
+// analyze decodes every word and marks those reachable from address zero
+func analyze(words []uint32) []line {
+	lines := make([]line, len(words))
+	for addr, word := range words {
+		inst, ok := Decode(uint32(addr), word)
+		lines[addr] = line{inst: inst, valid: ok}
+	}
+
+	// walk every path from address zero
+	work := []uint32{0}
+	for len(work) > 0 {
+		addr := work[len(work)-1]
+		work = work[:len(work)-1]
+		if int(addr) >= len(lines) || lines[addr].code || !lines[addr].valid {
+			continue
+		}
+		lines[addr].code = true
+		inst := lines[addr].inst
+		work = append(work, inst.Targets...)
+		if inst.Falls {
+			work = append(work, addr+1)
+		}
+	}
+	return lines
+}
+
+// Labels synthesizes names for the branch and jump targets (Lxxx) and the
+// absolute data references (Dxxx) of the code reachable from address zero
+func Labels(words []uint32) map[uint32]string {
+	return labelsOf(analyze(words))
+}
+
+func labelsOf(lines []line) map[uint32]string {
+	labels := make(map[uint32]string)
+	for _, l := range lines {
+		if !l.code {
+			continue
+		}
+		for _, t := range l.inst.Targets {
+			if int(t) < len(lines) {
+				labels[t] = fmt.Sprintf("L%03x", t)
+			}
+		}
+		if ref := l.inst.DataRef; ref != nil && int(*ref) < len(lines) {
+			if _, ok := labels[*ref]; !ok {
+				labels[*ref] = fmt.Sprintf("D%03x", *ref)
+			}
+		}
+	}
+	return labels
+}
+
+// Disassemble writes the annotated listing of words to w. Words that are
+// never reached from address zero are written as .word data.
+func Disassemble(w io.Writer, words []uint32) error {
+	lines := analyze(words)
+	labels := labelsOf(lines)
+
+	for addr, l := range lines {
+		a := uint32(addr)
+		label := ""
+		if name, ok := labels[a]; ok {
+			label = name + ":"
+		}
+
+		var text string
+		if l.code {
+			ops := make([]string, len(l.inst.Operands))
+			copy(ops, l.inst.Operands)
+			for _, t := range l.inst.Targets {
+				if name, ok := labels[t]; ok {
+					ops = append(ops, name)
+				} else {
+					ops = append(ops, fmt.Sprintf("%#x", t))
+				}
+			}
+			if ref := l.inst.DataRef; ref != nil {
+				if name, ok := labels[*ref]; ok {
+					ops[len(ops)-1] = name
+				}
+			}
+			text = fmt.Sprintf("%-6s%s", l.inst.Mnemonic, strings.Join(ops, ", "))
+		} else {
+			text = fmt.Sprintf("%-6s0x%08x", ".word", words[addr])
+		}
+		text = strings.TrimRight(text, " ")
+
+		if _, err := fmt.Fprintf(w, "%-7s %-26s // %03x: %08x\n", label, text, a, words[addr]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package disasm
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/t94j0/cpsc_3300_mips/assembler"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		addr uint32
+		word uint32
+		want string
+	}{
+		{0, 0x00000000, "hlt"},
+		{0, 0x00430821, "addu r1, r2, r3"},
+		{0, 0x70221802, "mul r3, r1, r2"},
+		{0, 0x00020fc3, "sra r1, r2, 31"},
+		{0, 0x03e00008, "jr r31"},
+		{0, 0x00a02009, "jalr r4, r5"},
+		{0, 0x2401ffff, "addiu r1, r0, -1"},
+		{0, 0x3841ffff, "xori r1, r2, 0xffff"},
+		{0, 0x3c011234, "lui r1, 0x1234"},
+		{0, 0xac41fffc, "sw r1, -4(r2)"},
+		{6, 0x1880fffc, "blez r4 -> 3"},
+		{2, 0x10220001, "beq r1, r2 -> 4"},
+		{0, 0x0c000010, "jal -> 16"},
+	}
+	for _, tt := range tests {
+		inst, ok := Decode(tt.addr, tt.word)
+		if !ok {
+			t.Errorf("%08x: not decoded", tt.word)
+			continue
+		}
+		got := strings.TrimSpace(inst.Mnemonic + " " + strings.Join(inst.Operands, ", "))
+		for _, target := range inst.Targets {
+			got += fmt.Sprintf(" -> %d", target)
+		}
+		if got != tt.want {
+			t.Errorf("%08x: got %q, want %q", tt.word, got, tt.want)
+		}
+	}
+
+	for _, word := range []uint32{0xfc000000, 0x0000003f, 0x70221803} {
+		if _, ok := Decode(0, word); ok {
+			t.Errorf("%08x: decoded an illegal instruction", word)
+		}
+	}
+}
+
+func readHex(t *testing.T, path string) []uint32 {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var words []uint32
+	for _, line := range strings.Fields(string(b)) {
+		var w uint32
+		if _, err := fmt.Sscanf(line, "%x", &w); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		words = append(words, w)
+	}
+	return words
+}
+
+// TestRoundTrip disassembles the README images and assembles the listing
+// back to the same words
+func TestRoundTrip(t *testing.T) {
+	for _, name := range []string{"readme_sub", "readme_loop"} {
+		t.Run(name, func(t *testing.T) {
+			words := readHex(t, filepath.Join("testdata", name+".hex"))
+
+			var listing bytes.Buffer
+			if err := Disassemble(&listing, words); err != nil {
+				t.Fatal(err)
+			}
+			again, err := assembler.Assemble("listing.s", &listing)
+			if err != nil {
+				t.Fatalf("%v\n%s", err, listing.String())
+			}
+			if fmt.Sprint(again.Words) != fmt.Sprint(words) {
+				t.Errorf("got  %08x\nwant %08x", again.Words, words)
+			}
+		})
+	}
+}
+
+func TestDataAndLabels(t *testing.T) {
+	// j 4; two data words; an unreachable word; lw r1, 1(r0); hlt
+	words := []uint32{0x08000004, 0x22, 0x23, 0x24, 0x8c010001, 0}
+
+	labels := Labels(words)
+	if labels[4] != "L004" || labels[1] != "D001" || len(labels) != 2 {
+		t.Errorf("labels = %v", labels)
+	}
+
+	var out bytes.Buffer
+	if err := Disassemble(&out, words); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	for i, want := range []string{"j     L004", ".word 0x00000022", ".word 0x00000023", ".word 0x00000024", "lw    r1, D001", "hlt"} {
+		if !strings.Contains(lines[i], want) {
+			t.Errorf("line %d = %q, want %q", i, lines[i], want)
+		}
+	}
+}
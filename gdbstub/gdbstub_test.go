@@ -0,0 +1,145 @@
+package gdbstub
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	machine "github.com/t94j0/cpsc_3300_mips/machine"
+)
+
+// client is a scripted gdb speaking to a Server over an in-memory pipe
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+	errc chan error
+}
+
+func newClient(t *testing.T, words ...uint32) *client {
+	m := machine.NewMachine()
+	m.LoadWords(words)
+	server, conn := net.Pipe()
+	c := &client{t: t, conn: conn, r: bufio.NewReader(conn), errc: make(chan error, 1)}
+	go func() { c.errc <- NewServer(m).Serve(server) }()
+	return c
+}
+
+func (c *client) send(pkt string) {
+	c.t.Helper()
+	if _, err := fmt.Fprintf(c.conn, "$%s#%02x", pkt, checksum([]byte(pkt))); err != nil {
+		c.t.Fatalf("send %q: %v", pkt, err)
+	}
+	if b, err := c.r.ReadByte(); err != nil || b != '+' {
+		c.t.Fatalf("send %q: got ack %q, %v", pkt, b, err)
+	}
+}
+
+func (c *client) reply() string {
+	c.t.Helper()
+	if b, err := c.r.ReadByte(); err != nil || b != '$' {
+		c.t.Fatalf("reply: got %q, %v", b, err)
+	}
+	body, err := c.r.ReadString('#')
+	if err != nil {
+		c.t.Fatalf("reply: %v", err)
+	}
+	body = strings.TrimSuffix(body, "#")
+	sum := make([]byte, 2)
+	if _, err := c.r.Read(sum[:1]); err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := c.r.Read(sum[1:]); err != nil {
+		c.t.Fatal(err)
+	}
+	if want, _ := strconv.ParseUint(string(sum), 16, 8); uint8(want) != checksum([]byte(body)) {
+		c.t.Fatalf("reply %q: bad checksum %s", body, sum)
+	}
+	return body
+}
+
+func (c *client) expect(pkt, want string) {
+	c.t.Helper()
+	c.send(pkt)
+	if got := c.reply(); got != want {
+		c.t.Errorf("%s: got %q, want %q", pkt, got, want)
+	}
+}
+
+var program = []uint32{
+	0x24010005, // addiu r1, r0, 5
+	0x24020007, // addiu r2, r0, 7
+	0x00221821, // addu  r3, r1, r2
+	0x00000000, // hlt
+	0x00000000, // data
+}
+
+func TestSession(t *testing.T) {
+	c := newClient(t, program...)
+
+	c.expect("qSupported:multiprocess+", "PacketSize=4000;QStartNoAckMode+")
+	c.expect("?", "S05")
+
+	c.send("g")
+	regs := c.reply()
+	if len(regs) != numRegs*8 || regs != strings.Repeat("0", numRegs*8) {
+		t.Fatalf("g: got %q", regs)
+	}
+	c.expect("G"+regs[:8]+"00000011"+regs[16:], "OK")
+	c.expect("p1", "00000011")
+	c.expect("p99999", "E01")
+
+	c.expect("m0,8", "2401000524020007")
+	c.expect("m2,4", "00052402")
+	c.expect("M10,4:deadbeef", "OK")
+	c.expect("m10,4", "deadbeef")
+	c.expect("m100,4", "E14")
+
+	c.expect("Z0,8,4", "OK")
+	c.expect("c", "S05")
+	c.expect("p25", "00000008")
+	c.expect("p1", "00000005")
+	c.expect("z0,8,4", "OK")
+
+	c.expect("s", "S05")
+	c.expect("p25", "0000000c")
+	c.expect("p3", "0000000c")
+
+	c.expect("c", "W00")
+	c.expect("?", "W00")
+	c.expect("s", "W00")
+
+	c.send("k")
+	if err := <-c.errc; err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+}
+
+func TestInterrupt(t *testing.T) {
+	// j 0 spins forever
+	c := newClient(t, 0x08000000)
+
+	c.send("c")
+	if _, err := c.conn.Write([]byte{0x03}); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.reply(); got != "S02" {
+		t.Errorf("interrupt: got %q, want S02", got)
+	}
+	c.expect("?", "S02")
+	c.send("k")
+	if err := <-c.errc; err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+}
+
+func TestFaultSignals(t *testing.T) {
+	c := newClient(t, 0xfc000000)
+	c.expect("s", "S04")
+	c.expect("?", "S04")
+	c.send("k")
+	<-c.errc
+}
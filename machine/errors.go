@@ -0,0 +1,50 @@
+package machine
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of execution faults. Use errors.Is to test a returned error.
+var (
+	ErrIllegalInstruction = errors.New("illegal instruction")
+	ErrPCOutOfRange       = errors.New("pc out of range")
+	ErrMemoryOutOfRange   = errors.New("memory address out of range")
+)
+
+// Fault describes an instruction that could not be executed
+type Fault struct {
+	// Kind is one of the Err* values above
+	Kind error
+	// PC is the address of the faulting instruction
+	PC uint32
+	// Inst is the faulting instruction word (zero when it could not be fetched)
+	Inst uint32
+	// Addr is the data address for ErrMemoryOutOfRange
+	Addr uint32
+	// Registers is a snapshot taken when the fault was raised
+	Registers [32]uint32
+}
+
+func (f *Fault) Error() string {
+	switch f.Kind {
+	case ErrPCOutOfRange:
+		return fmt.Sprintf("%v: pc %03x", f.Kind, f.PC)
+	case ErrMemoryOutOfRange:
+		return fmt.Sprintf("%v: address %03x accessed by %08x at pc %03x", f.Kind, f.Addr, f.Inst, f.PC)
+	}
+	return fmt.Sprintf("%v: %08x at pc %03x", f.Kind, f.Inst, f.PC)
+}
+
+func (f *Fault) Unwrap() error {
+	return f.Kind
+}
+
+// fault builds a Fault for the instruction currently in ir
+func (m *Machine) fault(kind error, addr uint32) *Fault {
+	f := &Fault{Kind: kind, PC: m.ir, Addr: addr, Registers: m.registers}
+	if int(m.ir) < len(m.memory) {
+		f.Inst = m.memory[m.ir]
+	}
+	return f
+}
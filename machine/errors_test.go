@@ -0,0 +1,76 @@
+package machine
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFaultKinds(t *testing.T) {
+	tests := []struct {
+		name  string
+		image []uint32
+		kind  error
+		pc    uint32
+		inst  uint32
+		addr  uint32
+	}{
+		{"unknown opcode", []uint32{0xfc000000}, ErrIllegalInstruction, 0, 0xfc000000, 0},
+		{"unknown funct", []uint32{0x0000003f}, ErrIllegalInstruction, 0, 0x0000003f, 0},
+		{"bad mul funct", []uint32{0x70221803}, ErrIllegalInstruction, 0, 0x70221803, 0},
+		{"lw past memory", []uint32{0x8c0103ff, 0}, ErrMemoryOutOfRange, 0, 0x8c0103ff, 0x3ff},
+		{"sw past memory", []uint32{0xac0103ff, 0}, ErrMemoryOutOfRange, 0, 0xac0103ff, 0x3ff},
+		{"lw negative offset", []uint32{0x8c01ffff, 0}, ErrMemoryOutOfRange, 0, 0x8c01ffff, 0xffffffff},
+		{"sw negative offset", []uint32{0xac01fffe, 0}, ErrMemoryOutOfRange, 0, 0xac01fffe, 0xfffffffe},
+		{"fall off the end", []uint32{0x24010001}, ErrPCOutOfRange, 1, 0, 0},
+		{"jump out", []uint32{0x08000100}, ErrPCOutOfRange, 0x100, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMachine()
+			m.LoadWords(tt.image)
+			_, err := m.RunN(10)
+			if !errors.Is(err, tt.kind) {
+				t.Fatalf("got %v, want %v", err, tt.kind)
+			}
+			var f *Fault
+			if !errors.As(err, &f) {
+				t.Fatalf("%T is not a *Fault", err)
+			}
+			if f.PC != tt.pc || f.Inst != tt.inst || f.Addr != tt.addr {
+				t.Errorf("fault at pc %03x inst %08x addr %x, want %03x %08x %x",
+					f.PC, f.Inst, f.Addr, tt.pc, tt.inst, tt.addr)
+			}
+		})
+	}
+}
+
+func TestFaultHasNoEffect(t *testing.T) {
+	m := NewMachine()
+	// addiu r1, r0, 7; lw r2, 0x3ff(r0); hlt
+	m.LoadWords([]uint32{0x24010007, 0x8c0203ff, 0})
+
+	_, err := m.RunN(5)
+	var f *Fault
+	if !errors.As(err, &f) || f.Kind != ErrMemoryOutOfRange || f.Addr != 0x3ff {
+		t.Fatalf("got %v", err)
+	}
+	if f.Registers[1] != 7 {
+		t.Errorf("snapshot r1 = %x, want 7", f.Registers[1])
+	}
+	if m.PC() != 1 || m.IR() != 0 {
+		t.Errorf("pc %x ir %x after fault, want 1 and 0", m.PC(), m.IR())
+	}
+	if m.memoryAccess.instFetch != 1 || m.memoryAccess.load != 0 {
+		t.Errorf("counted %d fetches %d loads, want 1 and 0", m.memoryAccess.instFetch, m.memoryAccess.load)
+	}
+
+	// patch the word and retry the same instruction
+	m.memory[1] = 0x8c020000
+	if _, err := m.RunN(5); err != nil {
+		t.Fatal(err)
+	}
+	if r := m.registers[2]; r != 0x24010007 {
+		t.Errorf("r2 = %08x after retry", r)
+	}
+}
@@ -0,0 +1,333 @@
+package machine
+
+import (
+	"fmt"
+	"strings"
+
+	binary "github.com/t94j0/go-mips-instruction-format"
+)
+
+// Forwarding selects the bypass paths of the five-stage model
+type Forwarding uint8
+
+const (
+	// ForwardEXtoEX feeds a result from the EX/MEM latch back to the ALU
+	ForwardEXtoEX Forwarding = 1 << iota
+	// ForwardMEMtoEX feeds a result or loaded word from the MEM/WB latch
+	// back to the ALU
+	ForwardMEMtoEX
+
+	ForwardNone Forwarding = 0
+	ForwardAll             = ForwardEXtoEX | ForwardMEMtoEX
+)
+
+func (f Forwarding) String() string {
+	switch f {
+	case ForwardNone:
+		return "none"
+	case ForwardEXtoEX:
+		return "ex/mem->ex"
+	case ForwardMEMtoEX:
+		return "mem/wb->ex"
+	}
+	return "ex/mem->ex, mem/wb->ex"
+}
+
+// FiveStageConfig describes the pipeline timed by FiveStage
+type FiveStageConfig struct {
+	Forwarding Forwarding
+	// BranchInID resolves branches, jr and jalr in ID rather than EX. The
+	// comparator in ID is fed by the same forwarding paths as the ALU.
+	BranchInID bool
+}
+
+// StallCause is the reason an instruction was held back
+type StallCause int
+
+const (
+	StallNone StallCause = iota
+	// StallLoadUse waits for a word still being loaded
+	StallLoadUse
+	// StallData waits for an ALU result that no enabled path can forward
+	StallData
+	// StallControl is a fetch bubble after a taken branch or jump
+	StallControl
+	numStallCauses
+)
+
+func (c StallCause) String() string {
+	switch c {
+	case StallLoadUse:
+		return "load-use"
+	case StallData:
+		return "data"
+	case StallControl:
+		return "control"
+	}
+	return "none"
+}
+
+// StageTiming records the cycles in which one dynamic instruction entered
+// each stage. Cycles are numbered from 1. The instruction sits in ID from
+// ID until EX-1, so EX-ID-1 is the number of cycles it was stalled.
+type StageTiming struct {
+	PC       uint32
+	Mnemonic string
+	IF       uint64
+	ID       uint64
+	EX       uint64
+	MEM      uint64
+	WB       uint64
+	// Stall is why the instruction waited in ID
+	Stall StallCause
+	// Bubbles is the number of fetch cycles lost after this instruction
+	Bubbles uint64
+}
+
+// Stalls returns the number of cycles the instruction waited in ID
+func (t StageTiming) Stalls() uint64 {
+	return t.EX - t.ID - 1
+}
+
+// producer is the most recent in-flight writer of a register
+type producer struct {
+	valid bool
+	ex    uint64
+	load  bool
+}
+
+// FiveStage times the instruction stream of a Machine on a classic
+// IF/ID/EX/MEM/WB pipeline. The machine executes each instruction
+// functionally first, so its results are the same as without the model.
+// Branches are predicted untaken.
+type FiveStage struct {
+	config FiveStageConfig
+	m      *Machine
+
+	// fetch is the earliest cycle the next instruction can be fetched
+	fetch uint64
+	// ex is the EX cycle of the previous instruction
+	ex     uint64
+	cycles uint64
+	count  uint64
+	stalls [numStallCauses]uint64
+	regs   [32]producer
+}
+
+func NewFiveStage(m *Machine, config FiveStageConfig) *FiveStage {
+	return &FiveStage{config: config, m: m, fetch: 1}
+}
+
+// Step executes the next instruction on the machine and times it
+func (p *FiveStage) Step() (StageTiming, error) {
+	r, err := p.m.Step()
+	if err != nil {
+		return StageTiming{}, err
+	}
+
+	t := StageTiming{PC: r.PC, Mnemonic: r.Mnemonic, IF: p.fetch}
+	// the instruction moves to ID once the previous one has left it
+	t.ID = t.IF + 1
+	if p.ex > t.ID {
+		t.ID = p.ex
+	}
+
+	srcs, dest := regUse(r.Inst)
+	inID := p.config.BranchInID && resolvesLate(r.Mnemonic)
+	t.EX = t.ID + 1
+	for {
+		cause := p.blocked(srcs, t.EX, inID)
+		if cause == StallNone {
+			break
+		}
+		t.Stall = cause
+		t.EX++
+	}
+	t.MEM = t.EX + 1
+	t.WB = t.MEM + 1
+	p.stalls[t.Stall] += t.Stalls()
+
+	// the next instruction is fetched as this one moves to ID and follows
+	// it into ID, unless a taken branch or jump redirects fetch once it is
+	// resolved
+	p.fetch = t.ID
+	if r.Kind == ResultJump || r.Kind == ResultBranchTaken {
+		resolved := t.EX
+		if inID || !resolvesLate(r.Mnemonic) {
+			resolved = t.EX - 1
+		}
+		p.fetch = resolved + 1
+		if p.fetch+1 > t.EX {
+			t.Bubbles = p.fetch + 1 - t.EX
+		}
+		p.stalls[StallControl] += t.Bubbles
+	}
+
+	if dest > 0 {
+		p.regs[dest] = producer{valid: true, ex: t.EX, load: r.Mnemonic == "lw"}
+	}
+	p.ex = t.EX
+	p.cycles = t.WB
+	p.count++
+	return t, nil
+}
+
+// blocked reports why the sources cannot be read by an instruction entering
+// EX in cycle ex. inID means they are needed by the comparator in ID, one
+// cycle earlier.
+func (p *FiveStage) blocked(srcs []uint16, ex uint64, inID bool) StallCause {
+	use := ex
+	if inID {
+		use--
+	}
+	for _, s := range srcs {
+		q := p.regs[s]
+		if !q.valid || p.available(q, use, inID) {
+			continue
+		}
+		if q.load {
+			return StallLoadUse
+		}
+		return StallData
+	}
+	return StallNone
+}
+
+// available reports whether q's result can be read in cycle use
+func (p *FiveStage) available(q producer, use uint64, inID bool) bool {
+	wb := q.ex + 2
+	// the register file is written in the first half of WB and read in
+	// the second half of ID
+	read := use
+	if !inID {
+		read--
+	}
+	switch {
+	case read >= wb:
+		return true
+	case use == q.ex+1:
+		return !q.load && p.config.Forwarding&ForwardEXtoEX != 0
+	case use == q.ex+2:
+		return p.config.Forwarding&ForwardMEMtoEX != 0
+	}
+	return false
+}
+
+// Run executes and times instructions until the machine halts
+func (p *FiveStage) Run() error {
+	for !p.m.halt {
+		if _, err := p.Step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Cycles returns the cycle in which the last timed instruction left WB
+func (p *FiveStage) Cycles() uint64 {
+	return p.cycles
+}
+
+// Instructions returns the number of instructions timed, including hlt
+func (p *FiveStage) Instructions() uint64 {
+	return p.count
+}
+
+// CPI returns the average cycles per instruction
+func (p *FiveStage) CPI() float64 {
+	if p.count == 0 {
+		return 0
+	}
+	return float64(p.cycles) / float64(p.count)
+}
+
+// Stalls returns the stall cycles attributed to cause
+func (p *FiveStage) Stalls(cause StallCause) uint64 {
+	return p.stalls[cause]
+}
+
+// resolvesLate reports whether the instruction's target depends on a
+// register and so is resolved in EX unless branches resolve in ID. j and
+// jal are always resolved in ID.
+func resolvesLate(mnemonic string) bool {
+	switch mnemonic {
+	case "beq", "bne", "blez", "bgtz", "jr", "jalr":
+		return true
+	}
+	return false
+}
+
+// regUse returns the registers an instruction reads and the register it
+// writes, or -1 when it writes none
+func regUse(inst uint32) ([]uint16, int) {
+	s, t, d, _, funct := binary.GetRFormat(inst)
+	switch binary.GetOperation(inst) {
+	case 0x00:
+		switch {
+		case inst == 0:
+			return nil, -1
+		case funct == 0x08:
+			return []uint16{s}, -1
+		case funct == 0x09:
+			return []uint16{s}, int(d)
+		case funct == 0x00 || funct == 0x02 || funct == 0x03:
+			return []uint16{t}, int(d)
+		}
+		return []uint16{s, t}, int(d)
+	case 0x1c:
+		return []uint16{s, t}, int(d)
+	case 0x02:
+		return nil, -1
+	case 0x03:
+		return nil, 31
+	case 0x04, 0x05:
+		return []uint16{s, t}, -1
+	case 0x06, 0x07:
+		return []uint16{s}, -1
+	case 0x0f:
+		return nil, int(t)
+	case 0x2b:
+		return []uint16{s, t}, -1
+	}
+	return []uint16{s}, int(t)
+}
+
+// SetFiveStage makes Execute time the program on the five-stage pipeline
+// described by config instead of printing the pairing analysis. A nil
+// config restores the pairing analysis.
+func (m *Machine) SetFiveStage(config *FiveStageConfig) {
+	m.fiveStageConfig = config
+}
+
+func (m *Machine) executeFiveStage() error {
+	fmt.Println("five-stage pipeline timing")
+	m.fiveStage = NewFiveStage(m, *m.fiveStageConfig)
+	for !m.halt {
+		t, err := m.fiveStage.Step()
+		if err != nil {
+			fmt.Println()
+			return err
+		}
+		fmt.Printf("%03x: %-6s", t.PC, t.Mnemonic)
+		var notes []string
+		if n := t.Stalls(); n > 0 {
+			notes = append(notes, plural(n, t.Stall.String()+" stall"))
+		}
+		if t.Bubbles > 0 {
+			notes = append(notes, plural(t.Bubbles, "control bubble"))
+		}
+		if len(notes) > 0 {
+			fmt.Printf("%13s// %s", " ", strings.Join(notes, ", "))
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+	return nil
+}
+
+func plural(n uint64, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
@@ -0,0 +1,114 @@
+package machine
+
+import (
+	"testing"
+)
+
+func TestFiveStageStalls(t *testing.T) {
+	var (
+		all  = FiveStageConfig{Forwarding: ForwardAll}
+		none = FiveStageConfig{Forwarding: ForwardNone}
+		ex   = FiveStageConfig{Forwarding: ForwardEXtoEX}
+		mem  = FiveStageConfig{Forwarding: ForwardMEMtoEX}
+		id   = FiveStageConfig{Forwarding: ForwardAll, BranchInID: true}
+	)
+	// addiu r1, r0, 1; addu r2, r1, r1; hlt
+	aluUse := []uint32{0x24010001, 0x00211021, 0}
+	// lw r1, 3(r0); addu r2, r1, r1; hlt; data
+	loadUse := []uint32{0x8c010003, 0x00211021, 0, 5}
+	// beq r0, r0, 1; addiu r1, r0, 1; hlt
+	taken := []uint32{0x10000001, 0x24010001, 0}
+	// addiu r1, r0, 1; bne r1, r0, 0; hlt
+	aluBranch := []uint32{0x24010001, 0x14200000, 0}
+	// lw r1, 3(r0); bne r1, r0, 0; hlt; data
+	loadBranch := []uint32{0x8c010003, 0x14200000, 0, 5}
+
+	tests := []struct {
+		name    string
+		image   []uint32
+		config  FiveStageConfig
+		insts   uint64
+		cycles  uint64
+		loadUse uint64
+		data    uint64
+		control uint64
+	}{
+		{"independent", []uint32{0x24010001, 0x24020002, 0}, none, 3, 7, 0, 0, 0},
+		{"alu use forwarded", aluUse, all, 3, 7, 0, 0, 0},
+		{"alu use ex/mem only", aluUse, ex, 3, 7, 0, 0, 0},
+		{"alu use mem/wb only", aluUse, mem, 3, 8, 0, 1, 0},
+		{"alu use no forwarding", aluUse, none, 3, 9, 0, 2, 0},
+		{"load use forwarded", loadUse, all, 3, 8, 1, 0, 0},
+		{"load use ex/mem only", loadUse, ex, 3, 9, 2, 0, 0},
+		{"load use no forwarding", loadUse, none, 3, 9, 2, 0, 0},
+		{"taken branch in ex", taken, all, 2, 8, 0, 0, 2},
+		{"taken branch in id", taken, id, 2, 7, 0, 0, 1},
+		{"untaken branch", []uint32{0x14000001, 0}, all, 2, 6, 0, 0, 0},
+		{"jump", []uint32{0x08000002, 0, 0}, all, 2, 7, 0, 0, 1},
+		{"alu then branch in ex", aluBranch, all, 3, 9, 0, 0, 2},
+		{"alu then branch in id", aluBranch, id, 3, 9, 0, 1, 1},
+		{"load then branch in id", loadBranch, id, 3, 10, 2, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMachine()
+			m.LoadWords(tt.image)
+			p := NewFiveStage(m, tt.config)
+			if err := p.Run(); err != nil {
+				t.Fatal(err)
+			}
+			got := [...]uint64{p.Instructions(), p.Cycles(),
+				p.Stalls(StallLoadUse), p.Stalls(StallData), p.Stalls(StallControl)}
+			want := [...]uint64{tt.insts, tt.cycles, tt.loadUse, tt.data, tt.control}
+			if got != want {
+				t.Errorf("insts, cycles, load-use, data, control = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestFiveStageTiming(t *testing.T) {
+	m := NewMachine()
+	// lw r1, 3(r0); addu r2, r1, r1; hlt; data
+	m.LoadWords([]uint32{0x8c010003, 0x00211021, 0, 5})
+	p := NewFiveStage(m, FiveStageConfig{Forwarding: ForwardAll})
+
+	want := []StageTiming{
+		{PC: 0, Mnemonic: "lw", IF: 1, ID: 2, EX: 3, MEM: 4, WB: 5},
+		{PC: 1, Mnemonic: "addu", IF: 2, ID: 3, EX: 5, MEM: 6, WB: 7, Stall: StallLoadUse},
+		{PC: 2, Mnemonic: "hlt", IF: 3, ID: 5, EX: 6, MEM: 7, WB: 8},
+	}
+	for i, w := range want {
+		got, err := p.Step()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != w {
+			t.Errorf("instruction %d: got %+v, want %+v", i, got, w)
+		}
+	}
+}
+
+// TestFiveStageIsFunctional checks that timing a program does not change
+// what it computes
+func TestFiveStageIsFunctional(t *testing.T) {
+	image := []uint32{
+		0x24030005, 0x00000821, 0x24020001, 0x00220821,
+		0x24420001, 0x00432023, 0x1880fffc, 0,
+	}
+	plain := NewMachine()
+	plain.LoadWords(image)
+	if _, err := plain.RunN(1000); err != nil {
+		t.Fatal(err)
+	}
+
+	timed := NewMachine()
+	timed.LoadWords(image)
+	if err := NewFiveStage(timed, FiveStageConfig{}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if plain.Registers() != timed.Registers() || plain.PC() != timed.PC() {
+		t.Errorf("registers differ: %v and %v", plain.Registers(), timed.Registers())
+	}
+}
@@ -1,7 +1,6 @@
 package machine
 
 import (
-	"fmt"
 	"math"
 
 	"github.com/spf13/cast"
@@ -9,7 +8,7 @@ import (
 	binary "github.com/t94j0/go-mips-instruction-format"
 )
 
-type InstructionFunc func(m *Machine, inst uint32)
+type InstructionFunc func(m *Machine, inst uint32) error
 
 var opcodeInstructions = map[uint16]InstructionFunc{
 	0x00: zeroOpcode, 0x02: j, 0x03: jal, 0x04: beq, 0x05: bne, 0x06: blez,
@@ -21,215 +20,231 @@ var zeroInstructions = map[uint16]InstructionFunc{
 	0x03: sra, 0x02: srl, 0x23: subu, 0x26: xor,
 }
 
-func printInstruction(instruction string, ir uint32) {
-	f := "%03x: %-6s"
-	fmt.Printf(f, ir, instruction)
-}
-
-func zeroOpcode(m *Machine, inst uint32) {
+func zeroOpcode(m *Machine, inst uint32) error {
 	if inst == 0x0 {
 		m.memoryAccess.instFetch--
-		printInstruction("hlt", m.ir)
 		m.halt = true
-		return
+		m.last = StepResult{Mnemonic: "hlt", Kind: ResultHalt}
+		return nil
 	}
 	funct := uint16(binary.GetFunct(inst))
 	if _, ok := zeroInstructions[funct]; !ok {
-		panic(fmt.Sprintf("opcode does not exist: %08x", inst))
+		return m.fault(ErrIllegalInstruction, 0)
 	}
-	zeroInstructions[funct](m, inst)
+	return zeroInstructions[funct](m, inst)
 }
-func j(m *Machine, inst uint32) {
+func j(m *Machine, inst uint32) error {
 	m.transferControl.jump++
 	dst := binary.GetJFormat(inst)
-	printInstruction("j", m.ir)
 	m.pc = dst
+	m.jumped("j")
+	return nil
 }
-func jal(m *Machine, inst uint32) {
+func jal(m *Machine, inst uint32) error {
 	m.transferControl.jumpLink++
 	dst := binary.GetJFormat(inst)
-	printInstruction("jal", m.ir)
 	m.registers[31] = m.pc
 	m.pc = dst
+	m.jumped("jal")
+	return nil
 }
-func bne(m *Machine, inst uint32) {
+func bne(m *Machine, inst uint32) error {
 	sr, tr, immu := binary.GetIFormat(inst)
 	s, t := m.registers[sr], m.registers[tr]
 
-	printInstruction("bne", m.ir)
 	if s != t {
 		m.transferControl.takenBranch++
 		loc := int16(m.pc) + cast.ToInt16(immu)
 
 		m.pc = uint32(loc)
+		m.branched("bne", true)
 	} else {
 		m.transferControl.untakenBranch++
+		m.branched("bne", false)
 	}
+	return nil
 }
-func blez(m *Machine, inst uint32) {
+func blez(m *Machine, inst uint32) error {
 	s, _, immu := binary.GetIFormat(inst)
 	val := int32(m.registers[s])
-	printInstruction("blez", m.ir)
 	if val <= 0 {
 		m.transferControl.takenBranch++
 		loc := int16(m.ir) + int16(immu)
 		m.pc = uint32(loc)
+		m.branched("blez", true)
 	} else {
 		m.transferControl.untakenBranch++
+		m.branched("blez", false)
 
 	}
+	return nil
 }
-func bgtz(m *Machine, inst uint32) {
+func bgtz(m *Machine, inst uint32) error {
 	s, _, immu := binary.GetIFormat(inst)
 	valu := m.registers[s]
 	val := cast.ToInt32(valu)
-	printInstruction("bgtz", m.ir)
 	if val > 0 {
 		m.transferControl.takenBranch++
 		loc := int16(m.pc) + int16(immu)
 		m.pc = uint32(loc)
+		m.branched("bgtz", true)
 	} else {
 		m.transferControl.untakenBranch++
+		m.branched("bgtz", false)
 	}
+	return nil
 }
-func addiu(m *Machine, inst uint32) {
+func addiu(m *Machine, inst uint32) error {
 	m.instructionClass.alu++
 	s, t, imm := binary.GetIFormat(inst)
 	immCast := cast.ToInt16(imm)
 
 	sum := uint32(int32(m.registers[s]) + int32(immCast))
 
-	printInstruction("addiu", m.ir)
-	m.writeTo = int(t)
+	m.wrote("addiu", t)
 	m.registers[t] = sum
+	return nil
 }
-func slti(m *Machine, inst uint32) {
+func slti(m *Machine, inst uint32) error {
 	m.instructionClass.alu++
 	su, tu, immu := binary.GetIFormat(inst)
 	s, imm := int32(m.registers[su]), int32(immu)
-	printInstruction("slti", m.ir)
 	if s < imm {
 
 		m.registers[tu] = 1
 	} else {
 		m.registers[tu] = 0
 	}
-	m.writeTo = int(tu)
+	m.wrote("slti", tu)
+	return nil
 }
-func lui(m *Machine, inst uint32) {
+func lui(m *Machine, inst uint32) error {
 	m.instructionClass.alu++
 	_, tu, imm := binary.GetIFormat(inst)
 	val := cast.ToUint32(imm) << 16
-	printInstruction("lui", m.ir)
 	m.registers[tu] = uint32(val)
-	m.writeTo = int(tu)
+	m.wrote("lui", tu)
+	return nil
 }
-func xori(m *Machine, inst uint32) {
+func xori(m *Machine, inst uint32) error {
 	m.instructionClass.alu++
 	su, tu, imm := binary.GetIFormat(inst)
 	s := m.registers[su]
 	xoriVal := s ^ uint32(imm)
-	printInstruction("xori", m.ir)
 	m.registers[tu] = xoriVal
-	m.writeTo = int(tu)
+	m.wrote("xori", tu)
+	return nil
 }
-func mul(m *Machine, inst uint32) {
-	m.instructionClass.alu++
+func mul(m *Machine, inst uint32) error {
 	su, tu, du, _, funct := binary.GetRFormat(inst)
 	if funct != 0x2 {
-		panic(fmt.Sprintf("%x is not a valid instruction", inst))
+		return m.fault(ErrIllegalInstruction, 0)
 	}
+	m.instructionClass.alu++
 	s, t := m.registers[su], m.registers[tu]
 	prod := s * t
-	printInstruction("mul", m.ir)
 	m.registers[du] = prod
-	m.writeTo = int(du)
+	m.wrote("mul", du)
+	return nil
 }
-func lw(m *Machine, inst uint32) {
-	m.memoryAccess.load++
+func lw(m *Machine, inst uint32) error {
 	s, t, imm := binary.GetIFormat(inst)
-	printInstruction("lw", m.ir)
-	m.registers[t] = m.memory[s+imm]
-	m.writeTo = int(t)
+	addr := uint32(int32(s) + int32(int16(imm)))
+	if int(addr) >= len(m.memory) {
+		return m.fault(ErrMemoryOutOfRange, addr)
+	}
+	m.memoryAccess.load++
+	m.registers[t] = m.memory[addr]
+	m.wrote("lw", t)
+	return nil
 }
-func sw(m *Machine, inst uint32) {
-	m.memoryAccess.store++
+func sw(m *Machine, inst uint32) error {
 	s, t, imm := binary.GetIFormat(inst)
-	printInstruction("sw", m.ir)
-	m.memory[s+imm] = uint32(uint16(m.registers[t]))
+	addr := uint32(int32(s) + int32(int16(imm)))
+	if int(addr) >= len(m.memory) {
+		return m.fault(ErrMemoryOutOfRange, addr)
+	}
+	m.memoryAccess.store++
+	m.memory[addr] = uint32(uint16(m.registers[t]))
+	m.stored("sw", t, addr)
+	return nil
 }
-func beq(m *Machine, inst uint32) {
+func beq(m *Machine, inst uint32) error {
 	sr, tr, immu := binary.GetIFormat(inst)
 	s, t := m.registers[sr], m.registers[tr]
-	printInstruction("beq", m.ir)
 	if s == t {
 		m.transferControl.takenBranch++
 		loc := int16(m.ir) + int16(immu)
 
 		m.pc = uint32(loc)
+		m.branched("beq", true)
 	} else {
 		m.transferControl.untakenBranch++
+		m.branched("beq", false)
 	}
+	return nil
 }
-func addu(m *Machine, inst uint32) {
+func addu(m *Machine, inst uint32) error {
 	m.instructionClass.alu++
 	s, t, d, _, _ := binary.GetRFormat(inst)
 	sum := m.registers[s] + m.registers[t]
-	printInstruction("addu", m.ir)
 	m.registers[d] = sum
-	m.writeTo = int(d)
+	m.wrote("addu", d)
+	return nil
 }
-func and(m *Machine, inst uint32) {
+func and(m *Machine, inst uint32) error {
 	m.instructionClass.alu++
 	su, tu, du, _, _ := binary.GetRFormat(inst)
 	s, t := m.registers[su], m.registers[tu]
 	andVal := s & t
-	printInstruction("and", m.ir)
 	m.registers[du] = andVal
-	m.writeTo = int(du)
+	m.wrote("and", du)
+	return nil
 }
-func jalr(m *Machine, inst uint32) {
+func jalr(m *Machine, inst uint32) error {
 	m.transferControl.jumpLink++
 	s, _, d, _, _ := binary.GetRFormat(inst)
 	m.registers[d] = m.pc
-	printInstruction("jalr", m.ir)
 	m.pc = m.registers[s]
+	m.jumped("jalr")
 	m.writeTo = int(d)
+	return nil
 }
-func jr(m *Machine, inst uint32) {
+func jr(m *Machine, inst uint32) error {
 	m.transferControl.jump++
 	s, _, _, _, _ := binary.GetRFormat(inst)
-	printInstruction("jr", m.ir)
 	m.pc = m.registers[s]
+	m.jumped("jr")
+	return nil
 }
-func nor(m *Machine, inst uint32) {
+func nor(m *Machine, inst uint32) error {
 	m.instructionClass.alu++
 	su, tu, du, _, _ := binary.GetRFormat(inst)
 	s, t := m.registers[su], m.registers[tu]
 	norVal := ^(s | t)
-	printInstruction("nor", m.ir)
 	m.registers[du] = norVal
-	m.writeTo = int(du)
+	m.wrote("nor", du)
+	return nil
 }
-func or(m *Machine, inst uint32) {
+func or(m *Machine, inst uint32) error {
 	m.instructionClass.alu++
 	su, tu, du, _, _ := binary.GetRFormat(inst)
 	s, t := m.registers[su], m.registers[tu]
 	orVal := s | t
-	printInstruction("or", m.ir)
 	m.registers[du] = orVal
-	m.writeTo = int(du)
+	m.wrote("or", du)
+	return nil
 }
-func sll(m *Machine, inst uint32) {
+func sll(m *Machine, inst uint32) error {
 	m.instructionClass.alu++
 	_, tu, du, hu, _ := binary.GetRFormat(inst)
 	t := m.registers[tu]
 	sllVal := t << hu
-	printInstruction("sll", m.ir)
 	m.registers[du] = sllVal
-	m.writeTo = int(du)
+	m.wrote("sll", du)
+	return nil
 }
-func sra(m *Machine, inst uint32) {
+func sra(m *Machine, inst uint32) error {
 	m.instructionClass.alu++
 	_, tu, du, hu, _ := binary.GetRFormat(inst)
 	t := m.registers[tu]
@@ -237,33 +252,33 @@ func sra(m *Machine, inst uint32) {
 
 	sraVal := (t >> hu) | mask
 
-	printInstruction("sra", m.ir)
 	m.registers[du] = sraVal
-	m.writeTo = int(du)
+	m.wrote("sra", du)
+	return nil
 }
-func srl(m *Machine, inst uint32) {
+func srl(m *Machine, inst uint32) error {
 	m.instructionClass.alu++
 	_, tu, du, h, _ := binary.GetRFormat(inst)
 	t := m.registers[tu]
 	srlVal := t >> h
-	printInstruction("srl", m.ir)
 	m.registers[du] = srlVal
-	m.writeTo = int(du)
+	m.wrote("srl", du)
+	return nil
 }
-func subu(m *Machine, inst uint32) {
+func subu(m *Machine, inst uint32) error {
 	m.instructionClass.alu++
 	s, t, d, _, _ := binary.GetRFormat(inst)
 	diff := m.registers[s] - m.registers[t]
-	printInstruction("subu", m.ir)
 	m.registers[d] = diff
-	m.writeTo = int(d)
+	m.wrote("subu", d)
+	return nil
 }
-func xor(m *Machine, inst uint32) {
+func xor(m *Machine, inst uint32) error {
 	m.instructionClass.alu++
 	su, tu, du, _, _ := binary.GetRFormat(inst)
 	s, t := m.registers[su], m.registers[tu]
 	xorVal := s ^ t
-	printInstruction("xor", m.ir)
 	m.registers[du] = xorVal
-	m.writeTo = int(du)
+	m.wrote("xor", du)
+	return nil
 }
@@ -27,3 +27,9 @@ func (m *Machine) LoadFromReader(r io.Reader) error {
 
 	return nil
 }
+
+// LoadWords loads an already assembled image starting at address zero
+func (m *Machine) LoadWords(words []uint32) {
+	m.memory = make([]uint32, len(words))
+	copy(m.memory, words)
+}
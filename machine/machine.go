@@ -15,6 +15,10 @@ type Machine struct {
 	halt bool
 
 	writeTo int
+	// last describes the most recently executed instruction
+	last StepResult
+	// trace selects the behavioral simulation output instead of pairing
+	trace bool
 
 	memory    []uint32
 	registers [32]uint32
@@ -37,12 +41,21 @@ type Machine struct {
 	}
 
 	pipeline *Pipeline
+
+	fiveStageConfig *FiveStageConfig
+	fiveStage       *FiveStage
 }
 
 func NewMachine() *Machine {
 	return &Machine{writeTo: -1}
 }
 
+// SetTrace switches Execute between the instruction pairing analysis and
+// the behavioral simulation trace described in the README
+func (m *Machine) SetTrace(on bool) {
+	m.trace = on
+}
+
 func (m *Machine) cycle() {
 	if m.registers[0] != 0 {
 		m.registers[0] = 0
@@ -61,28 +74,88 @@ func (m *Machine) getOperations() (uint16, uint16, uint32) {
 	return inst, funct, op
 }
 
+// getNextOp decodes the word at pc without fetching it. A pc past the end of
+// memory decodes as zero; the fault is raised when it is actually fetched.
 func (m *Machine) getNextOp() (uint16, uint16, uint32) {
+	if int(m.pc) >= len(m.memory) {
+		return 0, 0, 0
+	}
 	op := m.memory[m.pc]
 	inst := uint16(binary.GetOperation(op))
 	funct := uint16(binary.GetFunct(op))
 	return inst, funct, op
 }
 
-func (m *Machine) runInstruction() {
+// runInstruction fetches and executes the instruction at pc. A faulting
+// instruction has no effect: it is not counted, registers and memory are
+// unchanged, and pc still addresses it so the caller may fix the cause and
+// retry.
+func (m *Machine) runInstruction() error {
+	if int(m.pc) >= len(m.memory) {
+		return &Fault{Kind: ErrPCOutOfRange, PC: m.pc, Registers: m.registers}
+	}
+	ir, writeTo := m.ir, m.writeTo
 	m.cycle()
 	m.memoryAccess.instFetch++
 	inst, _, _ := m.getOperations()
-	if _, ok := opcodeInstructions[inst]; !ok {
-		panic(fmt.Sprintf("opcode does not exist: %08x\n", m.memory[m.ir]))
+	var err error
+	if handler, ok := opcodeInstructions[inst]; ok {
+		err = handler(m, m.memory[m.ir])
+	} else {
+		err = m.fault(ErrIllegalInstruction, 0)
+	}
+	if err != nil {
+		m.memoryAccess.instFetch--
+		m.pc, m.ir, m.writeTo = m.ir, ir, writeTo
+		return err
 	}
-	opcodeInstructions[inst](m, m.memory[m.ir])
+	// r0 is hardwired to zero. Clearing it here rather than at the next
+	// fetch means the trace never shows a write to r0.
+	m.registers[0] = 0
+
+	m.last.PC = m.ir
+	m.last.Inst = m.memory[m.ir]
+	m.last.Target = m.pc
+	if m.last.Kind == ResultRegister || m.last.Kind == ResultStore {
+		m.last.Value = m.registers[m.last.Reg]
+	}
+	return nil
 }
 
-func (m *Machine) Execute() {
+// Execute runs the program until it halts or faults, printing the
+// instruction pairing analysis, the five-stage pipeline timing or, in trace
+// mode, the behavioral simulation.
+// A returned error is a *Fault.
+func (m *Machine) Execute() error {
+	if m.trace {
+		return m.executeTrace()
+	}
+	if m.fiveStageConfig != nil {
+		return m.executeFiveStage()
+	}
+
 	fmt.Println("instruction pairing analysis")
 	m.pipeline = NewPipeline(m)
 	for !m.halt {
-		m.pipeline.Schedule()
+		if err := m.pipeline.Schedule(); err != nil {
+			fmt.Println()
+			return err
+		}
 	}
 	fmt.Println()
+	return nil
+}
+
+func (m *Machine) executeTrace() error {
+	fmt.Println("pc   result of instruction at that location")
+	var err error
+	for !m.halt && err == nil {
+		if _, err = m.Step(); err == nil {
+			m.printResult()
+		}
+	}
+	fmt.Println()
+	m.PrintMemory()
+	fmt.Println()
+	return err
 }
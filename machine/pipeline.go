@@ -22,16 +22,30 @@ func NewPipeline(m *Machine) *Pipeline {
 	return &Pipeline{m: m}
 }
 
-func (p *Pipeline) Schedule() {
+func (p *Pipeline) Schedule() error {
 	op, funct, inst := p.m.getNextOp()
-	p.m.runInstruction()
+	if err := p.run(); err != nil {
+		return err
+	}
 	if p.shouldRunSecond(op, funct, inst) {
-		p.m.runInstruction()
+		if err := p.run(); err != nil {
+			return err
+		}
 		p.doubleIssue++
 		fmt.Printf("  // -- double issue --")
 	}
 	p.issueCycle++
 	p.flush()
+	return nil
+}
+
+// run executes the next instruction and prints its issue slot
+func (p *Pipeline) run() error {
+	if err := p.m.runInstruction(); err != nil {
+		return err
+	}
+	fmt.Printf("%03x: %-6s", p.m.ir, p.m.last.Mnemonic)
+	return nil
 }
 
 func (p *Pipeline) shouldRunSecond(oldOp, oldFunct uint16, oldInst uint32) bool {
@@ -57,13 +57,46 @@ total               %3d`, j, jal, taken, untaken, total)
 }
 
 func (m *Machine) PrintBehavorialSimulation() {
-	fmt.Println("\n" + `simple MIPS-like machine with instruction pairing
+	title := "simple MIPS-like machine with instruction pairing"
+	switch {
+	case m.trace:
+		title = "behavioral simulation of simple MIPS-like machine"
+	case m.fiveStageConfig != nil:
+		title = "simple MIPS-like machine with five-stage pipeline"
+	}
+	fmt.Println("\n" + title + `
   (all values are shown in hexadecimal)`)
 	fmt.Println()
 }
 
+// printResult prints the trace line of the last executed instruction
+func (m *Machine) printResult() {
+	last := m.last
+	if last.Kind == ResultHalt {
+		fmt.Printf("%03x: %s\n", last.PC, last.Mnemonic)
+		return
+	}
+
+	fmt.Printf("%03x: %-6s- ", last.PC, last.Mnemonic)
+	switch last.Kind {
+	case ResultRegister:
+		fmt.Printf("register r[%d] now contains 0x%08x\n", last.Reg, last.Value)
+	case ResultStore:
+		fmt.Printf("register r[%d] value stored in memory\n", last.Reg)
+	case ResultJump:
+		fmt.Printf("jump to 0x%08x\n", last.Target)
+	case ResultBranchTaken:
+		fmt.Printf("branch taken to 0x%08x\n", last.Target)
+	case ResultBranchUntaken:
+		fmt.Println("branch untaken")
+	}
+}
+
 func (m *Machine) PrintInstructionPairing() {
 	pipe := m.pipeline
+	if pipe == nil {
+		return
+	}
 	var perc float64
 	if pipe.issueCycle != 0 {
 		perc = (float64(pipe.doubleIssue) / float64(pipe.issueCycle)) * 100.0
@@ -76,3 +109,28 @@ func (m *Machine) PrintInstructionPairing() {
 	fmt.Printf("  structural stops %4d (%d of which would also stop on a data dep.)\n", pipe.structuralStop, pipe.strData)
 	fmt.Printf("  data dep. stops  %4d\n", pipe.dataDepStop)
 }
+
+// PrintFiveStageTiming prints the cycle counts of the five-stage model after
+// Execute. It prints nothing when the model was not used.
+func (m *Machine) PrintFiveStageTiming() {
+	p := m.fiveStage
+	if p == nil {
+		return
+	}
+	branch := "ex"
+	if p.config.BranchInID {
+		branch = "id"
+	}
+	total := p.Stalls(StallLoadUse) + p.Stalls(StallData) + p.Stalls(StallControl)
+
+	fmt.Printf("\nfive-stage pipeline counts (includes hlt instruction)\n")
+	fmt.Printf("  forwarding       %s\n", p.config.Forwarding)
+	fmt.Printf("  branch stage     %s\n", branch)
+	fmt.Printf("  instructions     %4d\n", p.Instructions())
+	fmt.Printf("  cycles           %4d\n", p.Cycles())
+	fmt.Printf("  CPI              %7.2f\n", p.CPI())
+	fmt.Printf("  stall cycles     %4d\n", total)
+	fmt.Printf("    load-use       %4d\n", p.Stalls(StallLoadUse))
+	fmt.Printf("    data           %4d\n", p.Stalls(StallData))
+	fmt.Printf("    control        %4d\n", p.Stalls(StallControl))
+}
@@ -0,0 +1,167 @@
+package machine
+
+import (
+	"fmt"
+)
+
+// ResultKind classifies the visible effect of an instruction
+type ResultKind int
+
+const (
+	ResultRegister ResultKind = iota
+	ResultStore
+	ResultJump
+	ResultBranchTaken
+	ResultBranchUntaken
+	ResultHalt
+)
+
+// StepResult describes one executed instruction
+type StepResult struct {
+	// PC is the address the instruction was fetched from
+	PC       uint32
+	Inst     uint32
+	Mnemonic string
+	Kind     ResultKind
+	// Reg is the register written (ResultRegister) or stored (ResultStore)
+	Reg uint16
+	// Value is the value written to Reg or stored from it
+	Value uint32
+	// Addr is the memory address of a store
+	Addr uint32
+	// Target is the pc of the next instruction
+	Target uint32
+}
+
+func (m *Machine) wrote(mnemonic string, reg uint16) {
+	m.writeTo = int(reg)
+	m.last = StepResult{Mnemonic: mnemonic, Kind: ResultRegister, Reg: reg}
+}
+
+func (m *Machine) stored(mnemonic string, reg uint16, addr uint32) {
+	m.last = StepResult{Mnemonic: mnemonic, Kind: ResultStore, Reg: reg, Addr: addr}
+}
+
+func (m *Machine) jumped(mnemonic string) {
+	m.last = StepResult{Mnemonic: mnemonic, Kind: ResultJump}
+}
+
+func (m *Machine) branched(mnemonic string, taken bool) {
+	kind := ResultBranchUntaken
+	if taken {
+		kind = ResultBranchTaken
+	}
+	m.last = StepResult{Mnemonic: mnemonic, Kind: kind}
+}
+
+// Step executes a single instruction without pairing analysis or output.
+// Stepping a halted machine does nothing and returns the result of the hlt.
+func (m *Machine) Step() (StepResult, error) {
+	if m.halt {
+		return m.last, nil
+	}
+	if err := m.runInstruction(); err != nil {
+		return StepResult{}, err
+	}
+	return m.last, nil
+}
+
+// RunN executes up to n instructions, stopping early at a halt. It returns
+// the number of instructions executed.
+func (m *Machine) RunN(n int) (int, error) {
+	for i := 0; i < n; i++ {
+		if m.halt {
+			return i, nil
+		}
+		if _, err := m.Step(); err != nil {
+			return i, err
+		}
+	}
+	return n, nil
+}
+
+// RunUntil executes instructions until the next one to run is at pc, or the
+// machine halts. It returns the number of instructions executed.
+func (m *Machine) RunUntil(pc uint32) (int, error) {
+	n := 0
+	for !m.halt && m.pc != pc {
+		if _, err := m.Step(); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Halted reports whether a hlt instruction has executed
+func (m *Machine) Halted() bool {
+	return m.halt
+}
+
+// PC returns the address of the next instruction
+func (m *Machine) PC() uint32 {
+	return m.pc
+}
+
+// IR returns the address of the most recently executed instruction
+func (m *Machine) IR() uint32 {
+	return m.ir
+}
+
+// Register returns the value of register n
+func (m *Machine) Register(n int) (uint32, error) {
+	if n < 0 || n >= len(m.registers) {
+		return 0, fmt.Errorf("register r%d out of range", n)
+	}
+	return m.registers[n], nil
+}
+
+// Registers returns a snapshot of all 32 registers
+func (m *Machine) Registers() [32]uint32 {
+	return m.registers
+}
+
+// MemorySize returns the number of memory words
+func (m *Machine) MemorySize() int {
+	return len(m.memory)
+}
+
+// Word returns the memory word at addr
+func (m *Machine) Word(addr uint32) (uint32, error) {
+	if int(addr) >= len(m.memory) {
+		return 0, fmt.Errorf("%w: %03x", ErrMemoryOutOfRange, addr)
+	}
+	return m.memory[addr], nil
+}
+
+// Memory returns a copy of the loaded memory image
+func (m *Machine) Memory() []uint32 {
+	words := make([]uint32, len(m.memory))
+	copy(words, m.memory)
+	return words
+}
+
+// SetRegister sets register n. Writes to r0 are ignored.
+func (m *Machine) SetRegister(n int, value uint32) error {
+	if n < 0 || n >= len(m.registers) {
+		return fmt.Errorf("register r%d out of range", n)
+	}
+	if n != 0 {
+		m.registers[n] = value
+	}
+	return nil
+}
+
+// SetPC sets the address of the next instruction
+func (m *Machine) SetPC(pc uint32) {
+	m.pc = pc
+}
+
+// SetWord changes the memory word at addr
+func (m *Machine) SetWord(addr, value uint32) error {
+	if int(addr) >= len(m.memory) {
+		return fmt.Errorf("%w: %03x", ErrMemoryOutOfRange, addr)
+	}
+	m.memory[addr] = value
+	return nil
+}
@@ -0,0 +1,108 @@
+package machine
+
+import (
+	"testing"
+)
+
+// countdown is addiu r1, r0, 3; addiu r1, r1, -1; bgtz r1, -2; sw r1, 5(r0);
+// hlt; then a data word
+var countdown = []uint32{0x24010003, 0x2421ffff, 0x1c20fffe, 0xac010005, 0, 0}
+
+func TestRunStops(t *testing.T) {
+	tests := []struct {
+		name   string
+		run    func(m *Machine) (int, error)
+		n      int
+		pc     uint32
+		halted bool
+	}{
+		{"RunN zero", func(m *Machine) (int, error) { return m.RunN(0) }, 0, 0, false},
+		{"RunN count", func(m *Machine) (int, error) { return m.RunN(4) }, 4, 2, false},
+		{"RunN halt", func(m *Machine) (int, error) { return m.RunN(100) }, 9, 5, true},
+		{"RunUntil pc", func(m *Machine) (int, error) { return m.RunUntil(3) }, 7, 3, false},
+		{"RunUntil here", func(m *Machine) (int, error) { return m.RunUntil(0) }, 0, 0, false},
+		{"RunUntil halt", func(m *Machine) (int, error) { return m.RunUntil(0x3ff) }, 9, 5, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMachine()
+			m.LoadWords(countdown)
+			n, err := tt.run(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != tt.n || m.PC() != tt.pc || m.Halted() != tt.halted {
+				t.Errorf("ran %d to pc %03x halted %v, want %d %03x %v",
+					n, m.PC(), m.Halted(), tt.n, tt.pc, tt.halted)
+			}
+		})
+	}
+}
+
+func TestRunStopsAtFault(t *testing.T) {
+	m := NewMachine()
+	// addiu r1, r0, 1; illegal
+	m.LoadWords([]uint32{0x24010001, 0xfc000000})
+	if n, err := m.RunN(10); err == nil || n != 1 {
+		t.Errorf("RunN: ran %d, err %v", n, err)
+	}
+	if n, err := m.RunUntil(5); err == nil || n != 0 {
+		t.Errorf("RunUntil: ran %d, err %v", n, err)
+	}
+}
+
+func TestStepResults(t *testing.T) {
+	m := NewMachine()
+	m.LoadWords(countdown)
+
+	want := []StepResult{
+		{PC: 0, Inst: 0x24010003, Mnemonic: "addiu", Kind: ResultRegister, Reg: 1, Value: 3, Target: 1},
+		{PC: 1, Inst: 0x2421ffff, Mnemonic: "addiu", Kind: ResultRegister, Reg: 1, Value: 2, Target: 2},
+		{PC: 2, Inst: 0x1c20fffe, Mnemonic: "bgtz", Kind: ResultBranchTaken, Target: 1},
+		{PC: 1, Inst: 0x2421ffff, Mnemonic: "addiu", Kind: ResultRegister, Reg: 1, Value: 1, Target: 2},
+		{PC: 2, Inst: 0x1c20fffe, Mnemonic: "bgtz", Kind: ResultBranchTaken, Target: 1},
+		{PC: 1, Inst: 0x2421ffff, Mnemonic: "addiu", Kind: ResultRegister, Reg: 1, Value: 0, Target: 2},
+		{PC: 2, Inst: 0x1c20fffe, Mnemonic: "bgtz", Kind: ResultBranchUntaken, Target: 3},
+		{PC: 3, Inst: 0xac010005, Mnemonic: "sw", Kind: ResultStore, Reg: 1, Value: 0, Addr: 5, Target: 4},
+		{PC: 4, Inst: 0, Mnemonic: "hlt", Kind: ResultHalt, Target: 5},
+	}
+	for i, w := range want {
+		r, err := m.Step()
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if r != w {
+			t.Errorf("step %d: got %+v, want %+v", i, r, w)
+		}
+	}
+	if r, _ := m.Step(); r != want[len(want)-1] {
+		t.Errorf("step after halt: got %+v", r)
+	}
+}
+
+func TestRegisterBounds(t *testing.T) {
+	m := NewMachine()
+	for _, n := range []int{-1, 32} {
+		if _, err := m.Register(n); err == nil {
+			t.Errorf("Register(%d) succeeded", n)
+		}
+	}
+	for _, n := range []int{-1, 32} {
+		if err := m.SetRegister(n, 1); err == nil {
+			t.Errorf("SetRegister(%d) succeeded", n)
+		}
+	}
+	if err := m.SetRegister(0, 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetRegister(31, 5); err != nil {
+		t.Fatal(err)
+	}
+	if r0, _ := m.Register(0); r0 != 0 {
+		t.Errorf("r0 = %x after write", r0)
+	}
+	if r31, err := m.Register(31); err != nil || r31 != 5 {
+		t.Errorf("r31 = %x, %v; want 5", r31, err)
+	}
+}
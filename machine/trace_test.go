@@ -0,0 +1,72 @@
+package machine
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureStdout returns everything f prints to stdout
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	out := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- string(b)
+	}()
+	defer func() { os.Stdout = stdout }()
+
+	f()
+	w.Close()
+	return <-out
+}
+
+// TestTrace runs programs in trace mode and compares the output byte for
+// byte with the README formats
+func TestTrace(t *testing.T) {
+	for _, name := range []string{"trace_formats"} {
+		t.Run(name, func(t *testing.T) {
+			src, err := os.Open(filepath.Join("testdata", name+".hex"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer src.Close()
+			want, err := os.ReadFile(filepath.Join("testdata", name+".trace.golden"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			m := NewMachine()
+			m.SetTrace(true)
+			if err := m.LoadFromReader(src); err != nil {
+				t.Fatal(err)
+			}
+			var execErr error
+			got := captureStdout(t, func() {
+				m.PrintMemory()
+				m.PrintBehavorialSimulation()
+				execErr = m.Execute()
+				m.PrintInstructionClassCounts()
+				m.PrintMemoryAccessCounts()
+				m.PrintTransferControlCounts()
+			})
+			if execErr != nil {
+				t.Fatal(execErr)
+			}
+			if got != string(want) {
+				t.Errorf("output differs from golden\n got:\n%s\nwant:\n%s", got, want)
+			}
+			if strings.Count(got, "contents of memory") != 2 {
+				t.Errorf("want a memory dump before and after the run")
+			}
+		})
+	}
+}
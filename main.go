@@ -1,19 +1,249 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/t94j0/cpsc_3300_mips/assembler"
+	"github.com/t94j0/cpsc_3300_mips/debugger"
+	"github.com/t94j0/cpsc_3300_mips/disasm"
+	"github.com/t94j0/cpsc_3300_mips/gdbstub"
 	machine "github.com/t94j0/cpsc_3300_mips/machine"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "asm":
+			if err := runAsm(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		case "debug":
+			if err := runDebug(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		case "gdbserver":
+			if err := runGDBServer(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		case "disasm":
+			if err := runDisasm(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	trace := flag.Bool("trace", false, "print the behavioral simulation trace instead of the pairing analysis")
+	model := flag.String("pipeline", "pairing", "timing `model`: pairing or five-stage")
+	forward := flag.String("forward", "all", "five-stage forwarding `paths`: all, ex, mem or none")
+	branch := flag.String("branch", "id", "five-stage branch resolution `stage`: id or ex")
+	flag.Parse()
+
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
 	mac := machine.NewMachine()
+	mac.SetTrace(*trace)
+	switch *model {
+	case "pairing":
+		if set["forward"] || set["branch"] {
+			usageError("-forward and -branch need -pipeline five-stage")
+		}
+	case "five-stage":
+		if *trace {
+			usageError("-trace cannot be combined with -pipeline five-stage")
+		}
+		config, err := fiveStageConfig(*forward, *branch)
+		if err != nil {
+			usageError(err)
+		}
+		mac.SetFiveStage(config)
+	default:
+		usageError(fmt.Sprintf("unknown pipeline model %q", *model))
+	}
 	if err := mac.LoadFromStdin(); err != nil {
 		panic(err)
 	}
 	mac.PrintMemory()
 	mac.PrintBehavorialSimulation()
-	mac.Execute()
+	execErr := mac.Execute()
 	mac.PrintInstructionClassCounts()
 	mac.PrintMemoryAccessCounts()
 	mac.PrintTransferControlCounts()
 	mac.PrintInstructionPairing()
+	mac.PrintFiveStageTiming()
+	if execErr != nil {
+		fmt.Fprintln(os.Stderr, execErr)
+		os.Exit(1)
+	}
+}
+
+// usageError reports a bad combination of command line flags and exits
+func usageError(msg interface{}) {
+	fmt.Fprintln(os.Stderr, msg)
+	os.Exit(2)
+}
+
+// fiveStageConfig builds the five-stage model configuration from the
+// -forward and -branch flags
+func fiveStageConfig(forward, branch string) (*machine.FiveStageConfig, error) {
+	config := &machine.FiveStageConfig{}
+	switch forward {
+	case "all":
+		config.Forwarding = machine.ForwardAll
+	case "ex":
+		config.Forwarding = machine.ForwardEXtoEX
+	case "mem":
+		config.Forwarding = machine.ForwardMEMtoEX
+	case "none":
+		config.Forwarding = machine.ForwardNone
+	default:
+		return nil, fmt.Errorf("unknown forwarding %q", forward)
+	}
+	switch branch {
+	case "id":
+		config.BranchInID = true
+	case "ex":
+	default:
+		return nil, fmt.Errorf("unknown branch resolution stage %q", branch)
+	}
+	return config, nil
+}
+
+// runAsm assembles a source file (or stdin) into a hex image
+func runAsm(args []string) error {
+	fs := flag.NewFlagSet("asm", flag.ExitOnError)
+	out := fs.String("o", "", "write the image to `file` instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: cpsc_3300_mips asm [-o file] [source.s]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	name, src := "<stdin>", io.Reader(os.Stdin)
+	if fs.NArg() > 0 {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		name, src = fs.Arg(0), f
+	}
+
+	prog, err := assembler.Assemble(name, src)
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		return prog.WriteImage(os.Stdout)
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := prog.WriteImage(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// runDisasm prints the annotated assembly of a hex image (or stdin)
+func runDisasm(args []string) error {
+	fs := flag.NewFlagSet("disasm", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: cpsc_3300_mips disasm [image]")
+	}
+	fs.Parse(args)
+
+	mac := machine.NewMachine()
+	if err := load(mac, fs.Arg(0)); err != nil {
+		return err
+	}
+
+	return disasm.Disassemble(os.Stdout, mac.Memory())
+}
+
+// load fills mac from the named hex image, or from stdin when name is empty
+func load(mac *machine.Machine, name string) error {
+	if name == "" {
+		return mac.LoadFromStdin()
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return mac.LoadFromReader(f)
+}
+
+// runDebug starts the interactive debugger on an assembly source (.s) or a
+// hex image
+func runDebug(args []string) error {
+	fs := flag.NewFlagSet("debug", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: cpsc_3300_mips debug <program.s|image>")
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	mac := machine.NewMachine()
+	var labels map[string]uint32
+	if name := fs.Arg(0); strings.HasSuffix(name, ".s") {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		prog, err := assembler.Assemble(name, f)
+		if err != nil {
+			return err
+		}
+		mac.LoadWords(prog.Words)
+		labels = prog.Labels
+	} else if err := load(mac, name); err != nil {
+		return err
+	}
+
+	return debugger.New(mac, labels, os.Stdout).Run(os.Stdin)
+}
+
+// runGDBServer serves a hex image to a single gdb remote connection
+func runGDBServer(args []string) error {
+	fs := flag.NewFlagSet("gdbserver", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:1234", "listen on `host:port`")
+	verbose := fs.Bool("v", false, "log packets to stderr")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: cpsc_3300_mips gdbserver [-addr host:port] [-v] [image]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	mac := machine.NewMachine()
+	if err := load(mac, fs.Arg(0)); err != nil {
+		return err
+	}
+
+	srv := gdbstub.NewServer(mac)
+	if *verbose {
+		srv.Log = os.Stderr
+	} else {
+		fmt.Fprintf(os.Stderr, "listening on %s\n", *addr)
+	}
+	return srv.ListenAndServe(*addr)
 }